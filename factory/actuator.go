@@ -0,0 +1,285 @@
+package factory
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ActuatorConfig
+// Prefix is the path the handler is mounted on, "/actuator" when empty.
+// Reload returns the error of reloading the config, e.g. ConfigWatcher.Reload, reload is disabled when nil.
+// Auth wraps every endpoint, e.g. ActuatorBasicAuth.
+type ActuatorConfig struct {
+	Prefix string
+	Reload func() error
+	Auth   func(next http.Handler) http.Handler
+}
+
+const defaultActuatorPrefix = "/actuator"
+
+type actuator struct {
+	factory *LoggerFactory
+	reload  func() error
+	routes  map[string]map[string]http.HandlerFunc // path -> method -> handler
+}
+
+// NewActuator returns the management endpoints of f, mount it on a mux:
+//
+//	GET  {prefix}/loggers?prefix=x          the loggers with their level, override, appenders and suppressed entries
+//	GET  {prefix}/loggers/levels?prefix=x   the levels by logger name
+//	POST {prefix}/loggers/levels            {"prefix": "x", "level": "debug", "ttl": "15m"} sets the levels, for ttl when set
+//	GET  {prefix}/loggers/overrides         the levels set with a ttl and their remaining time
+//	GET  {prefix}/appenders                 the appenders with their health
+//	POST {prefix}/appenders/flush           flushes every appender
+//	POST {prefix}/reload                    calls ActuatorConfig.Reload
+func NewActuator(f *LoggerFactory, config ActuatorConfig) http.Handler {
+	prefix := strings.TrimSuffix(config.Prefix, "/")
+	if "" == config.Prefix {
+		prefix = defaultActuatorPrefix
+	}
+	a := &actuator{factory: f, reload: config.Reload}
+	a.routes = map[string]map[string]http.HandlerFunc{
+		prefix + "/loggers": {
+			http.MethodGet: a.getLoggers,
+		},
+		prefix + "/loggers/levels": {
+			http.MethodGet:  a.getLevels,
+			http.MethodPost: a.setLevels,
+			http.MethodPut:  a.setLevels,
+		},
+		prefix + "/loggers/overrides": {
+			http.MethodGet: a.getOverrides,
+		},
+		prefix + "/appenders": {
+			http.MethodGet: a.getAppenders,
+		},
+		prefix + "/appenders/flush": {
+			http.MethodPost: a.flushAppenders,
+		},
+		prefix + "/reload": {
+			http.MethodPost: a.reloadConfig,
+		},
+	}
+	var handler http.Handler = a
+	if config.Auth != nil {
+		handler = config.Auth(handler)
+	}
+	return handler
+}
+
+func (a *actuator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	methods, exists := a.routes[strings.TrimSuffix(r.URL.Path, "/")]
+	if !exists {
+		writeActuatorError(w, http.StatusNotFound, fmt.Errorf("no endpoint %s", r.URL.Path))
+		return
+	}
+	handler, exists := methods[r.Method]
+	if !exists {
+		allowed := make([]string, 0, len(methods))
+		for method := range methods {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeActuatorError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	handler(w, r)
+}
+
+type actuatorLogger struct {
+	Name       string            `json:"name"`
+	Level      string            `json:"level"`
+	Formatter  string            `json:"formatter"`
+	Appenders  []string          `json:"appenders"`
+	Override   *actuatorOverride `json:"override,omitempty"`
+	Suppressed uint64            `json:"suppressed"` // entries dropped by sampling
+}
+
+func (a *actuator) getLoggers(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	result := make([]actuatorLogger, 0, 16)
+	for _, logger := range factoryLoggers(a.factory) {
+		name := logger.Config.Name
+		if !matchesPrefix(prefix, name) {
+			continue
+		}
+		config := logger.GetConfig()
+		names := make([]string, 0, 1)
+		if merged, ok := config.Writer.(*mergedWriter); ok {
+			for _, d := range merged.delegates {
+				names = append(names, d.Name())
+			}
+		}
+		item := actuatorLogger{
+			Name:       name,
+			Level:      logLevelName(config.Level),
+			Formatter:  config.Formatter,
+			Appenders:  names,
+			Suppressed: config.sampler.suppressedCount(),
+		}
+		if o := a.factory.levelOverrideOf(name); o != nil {
+			override := newActuatorOverride(*o)
+			item.Override = &override
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	writeActuatorJson(w, http.StatusOK, result)
+}
+
+func (a *actuator) getLevels(w http.ResponseWriter, r *http.Request) {
+	writeActuatorJson(w, http.StatusOK, a.factory.GetLevels(r.URL.Query().Get("prefix")))
+}
+
+type actuatorLevel struct {
+	Prefix string `json:"prefix"`
+	Level  string `json:"level"`
+	Ttl    string `json:"ttl"` // a time.Duration, e.g. 15m
+}
+
+func (a *actuator) setLevels(w http.ResponseWriter, r *http.Request) {
+	level := new(actuatorLevel)
+	if err := json.NewDecoder(r.Body).Decode(level); err != nil {
+		writeActuatorError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return
+	}
+	var err error
+	if "" == level.Ttl {
+		err = a.factory.SetLevels(level.Prefix, level.Level)
+	} else {
+		ttl, parseErr := time.ParseDuration(level.Ttl)
+		if parseErr != nil {
+			writeActuatorError(w, http.StatusBadRequest, fmt.Errorf("invalid ttl %q", level.Ttl))
+			return
+		}
+		err = a.factory.SetLevelsFor(level.Prefix, level.Level, ttl)
+	}
+	if err != nil {
+		writeActuatorError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeActuatorJson(w, http.StatusOK, a.factory.GetLevels(level.Prefix))
+}
+
+type actuatorOverride struct {
+	Prefix    string    `json:"prefix"`
+	Level     string    `json:"level"`
+	Expires   time.Time `json:"expires"`
+	Remaining string    `json:"remaining"`
+}
+
+func (a *actuator) getOverrides(w http.ResponseWriter, r *http.Request) {
+	overrides := a.factory.GetLevelOverrides(r.URL.Query().Get("prefix"))
+	result := make([]actuatorOverride, 0, len(overrides))
+	for _, o := range overrides {
+		result = append(result, newActuatorOverride(o))
+	}
+	writeActuatorJson(w, http.StatusOK, result)
+}
+
+func newActuatorOverride(o LevelOverride) actuatorOverride {
+	return actuatorOverride{
+		Prefix:    o.Prefix,
+		Level:     o.Level,
+		Expires:   o.Expires,
+		Remaining: o.Remaining().Round(time.Second).String(),
+	}
+}
+
+type actuatorAppender struct {
+	Name    string  `json:"name"`
+	Healthy bool    `json:"healthy"`
+	Error   string  `json:"error,omitempty"`
+	Dropped *uint64 `json:"dropped,omitempty"` // appenders that drop entries: async, kafka, syslog
+}
+
+func (a *actuator) getAppenders(w http.ResponseWriter, _ *http.Request) {
+	all := registeredAppenders()
+	result := make([]actuatorAppender, 0, len(all))
+	for _, appender := range all {
+		item := actuatorAppender{Name: appender.Name(), Healthy: true}
+		if health, ok := appender.(interface{ Health() error }); ok {
+			if err := health.Health(); err != nil {
+				item.Healthy = false
+				item.Error = err.Error()
+			}
+		}
+		if dropping, ok := appender.(interface{ Dropped() uint64 }); ok {
+			dropped := dropping.Dropped()
+			item.Dropped = &dropped
+		}
+		result = append(result, item)
+	}
+	writeActuatorJson(w, http.StatusOK, result)
+}
+
+func (a *actuator) flushAppenders(w http.ResponseWriter, _ *http.Request) {
+	failed := make(map[string]string)
+	for _, appender := range registeredAppenders() {
+		if err := appender.Flush(); err != nil {
+			failed[appender.Name()] = err.Error()
+		}
+	}
+	if len(failed) > 0 {
+		writeActuatorJson(w, http.StatusInternalServerError, map[string]interface{}{"error": "flush failed", "appenders": failed})
+		return
+	}
+	writeActuatorJson(w, http.StatusOK, map[string]string{"status": "flushed"})
+}
+
+func (a *actuator) reloadConfig(w http.ResponseWriter, _ *http.Request) {
+	if a.reload == nil {
+		writeActuatorError(w, http.StatusNotImplemented, fmt.Errorf("reload is not configured"))
+		return
+	}
+	if err := a.reload(); err != nil {
+		writeActuatorError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeActuatorJson(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
+
+// registeredAppenders returns the appenders in use sorted by name.
+func registeredAppenders() []Appender {
+	appendersLk.Lock()
+	all := make([]Appender, 0, len(appenders))
+	for _, appender := range appenders {
+		all = append(all, appender)
+	}
+	appendersLk.Unlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+	return all
+}
+
+func writeActuatorJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeActuatorError(w http.ResponseWriter, status int, err error) {
+	writeActuatorJson(w, status, map[string]string{"error": err.Error()})
+}
+
+// ActuatorBasicAuth returns an ActuatorConfig.Auth accepting the requests with the user and password.
+func ActuatorBasicAuth(user string, password string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, p, ok := r.BasicAuth()
+			if !ok ||
+				subtle.ConstantTimeCompare([]byte(u), []byte(user)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="actuator"`)
+				writeActuatorError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package factory
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveActuator returns the status and the decoded json body of the request.
+func serveActuator(t *testing.T, handler http.Handler, method string, path string, body string) (int, interface{}, http.Header) {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var decoded interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+		t.Fatalf("%s %s: %v: %s", method, path, err, w.Body.String())
+	}
+	return w.Code, decoded, w.Header()
+}
+
+func testActuator(t *testing.T, reload func() error) (*LoggerFactory, http.Handler) {
+	t.Helper()
+	config, _ := testMemoryConfig(t, "json")
+	f := testFactory(t, "zap")
+	f.NewPackageLogger("act/a", config)
+	f.NewPackageLogger("act/b", config)
+	f.NewPackageLogger("other", config)
+	return f, NewActuator(f, ActuatorConfig{Prefix: "/mgmt/", Reload: reload, Auth: ActuatorBasicAuth("admin", "secret")})
+}
+
+func TestActuatorLevels(t *testing.T) {
+	_, handler := testActuator(t, nil)
+	status, body, _ := serveActuator(t, handler, http.MethodGet, "/mgmt/loggers?prefix=act", "")
+	loggers, _ := body.([]interface{})
+	if status != http.StatusOK || len(loggers) != 2 {
+		t.Fatalf("loggers %d %v", status, body)
+	}
+	if first := loggers[0].(map[string]interface{}); first["name"] != "act/a" || first["level"] != "Info" || first["formatter"] != "json" {
+		t.Errorf("logger %v", first)
+	}
+
+	status, body, _ = serveActuator(t, handler, http.MethodPost, "/mgmt/loggers/levels", `{"prefix":"act","level":"debug"}`)
+	if want := map[string]interface{}{"act/a": "Debug", "act/b": "Debug"}; status != http.StatusOK || !jsonEqual(body, want) {
+		t.Errorf("set levels %d %v", status, body)
+	}
+	status, body, _ = serveActuator(t, handler, http.MethodPut, "/mgmt/loggers/levels", `{"prefix":"act/b","level":"trace","ttl":"10m"}`)
+	if want := map[string]interface{}{"act/b": "Trace"}; status != http.StatusOK || !jsonEqual(body, want) {
+		t.Errorf("set levels for %d %v", status, body)
+	}
+	status, body, _ = serveActuator(t, handler, http.MethodGet, "/mgmt/loggers/levels", "")
+	if want := map[string]interface{}{"act/a": "Debug", "act/b": "Trace", "other": "Info"}; status != http.StatusOK || !jsonEqual(body, want) {
+		t.Errorf("levels %d %v", status, body)
+	}
+	status, body, _ = serveActuator(t, handler, http.MethodGet, "/mgmt/loggers/overrides", "")
+	overrides, _ := body.([]interface{})
+	if status != http.StatusOK || len(overrides) != 1 || overrides[0].(map[string]interface{})["remaining"] != "10m0s" {
+		t.Errorf("overrides %d %v", status, body)
+	}
+	_, body, _ = serveActuator(t, handler, http.MethodGet, "/mgmt/loggers?prefix=act/b", "")
+	if override := body.([]interface{})[0].(map[string]interface{})["override"]; override == nil {
+		t.Errorf("no override in %v", body)
+	}
+
+	for _, invalid := range []string{`{"prefix":"act","level":"loud"}`, `{"prefix":"act","level":"debug","ttl":"soon"}`, `{"prefix":"act","level":"debug","ttl":"-1m"}`, `{bad`} {
+		status, body, _ := serveActuator(t, handler, http.MethodPost, "/mgmt/loggers/levels", invalid)
+		if status != http.StatusBadRequest || body.(map[string]interface{})["error"] == nil {
+			t.Errorf("%s: %d %v", invalid, status, body)
+		}
+	}
+}
+
+func TestActuatorErrors(t *testing.T) {
+	_, handler := testActuator(t, nil)
+	r := httptest.NewRequest(http.MethodGet, "/mgmt/loggers", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("no auth: %d %v", w.Code, w.Header())
+	}
+	status, _, header := serveActuator(t, handler, http.MethodDelete, "/mgmt/loggers/levels", "")
+	if status != http.StatusMethodNotAllowed || header.Get("Allow") != "GET, POST, PUT" {
+		t.Errorf("delete: %d allow %q", status, header.Get("Allow"))
+	}
+	if status, _, _ := serveActuator(t, handler, http.MethodGet, "/mgmt/nope", ""); status != http.StatusNotFound {
+		t.Errorf("unknown endpoint: %d", status)
+	}
+	if status, _, _ := serveActuator(t, handler, http.MethodPost, "/mgmt/reload", ""); status != http.StatusNotImplemented {
+		t.Errorf("reload not configured: %d", status)
+	}
+}
+
+func TestActuatorAppendersAndReload(t *testing.T) {
+	reloadErr := errors.New("invalid config")
+	_, handler := testActuator(t, func() error { return reloadErr })
+	status, body, _ := serveActuator(t, handler, http.MethodGet, "/mgmt/appenders", "")
+	found := false
+	for _, a := range body.([]interface{}) {
+		appender := a.(map[string]interface{})
+		found = found || appender["name"] == "memory" && appender["healthy"] == true
+	}
+	if status != http.StatusOK || !found {
+		t.Errorf("appenders %d %v", status, body)
+	}
+	if status, body, _ := serveActuator(t, handler, http.MethodPost, "/mgmt/appenders/flush", ""); status != http.StatusOK {
+		t.Errorf("flush %d %v", status, body)
+	}
+	status, body, _ = serveActuator(t, handler, http.MethodPost, "/mgmt/reload/", "")
+	if status != http.StatusUnprocessableEntity || body.(map[string]interface{})["error"] != "invalid config" {
+		t.Errorf("reload %d %v", status, body)
+	}
+	reloadErr = nil
+	if status, body, _ := serveActuator(t, handler, http.MethodPost, "/mgmt/reload", ""); status != http.StatusOK {
+		t.Errorf("reload %d %v", status, body)
+	}
+}
+
+func jsonEqual(got interface{}, want interface{}) bool {
+	g, _ := json.Marshal(got)
+	w, _ := json.Marshal(want)
+	return string(g) == string(w)
+}
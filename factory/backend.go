@@ -0,0 +1,79 @@
+package factory
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Backend is the logging implementation behind a LoggerFactory.
+type Backend interface {
+	// NewDelegate builds the delegate of the logger described by config,
+	// the encoded entries are written to config.Writer.
+	NewDelegate(config *LoggerConfig) LoggerDelegate
+	// SetLevel applies config.Level to delegate, and returns the delegate the logger uses from then on.
+	SetLevel(delegate LoggerDelegate, config *LoggerConfig) LoggerDelegate
+}
+
+// LoggerDelegate receives the entries of a Logger, msg is already formatted.
+type LoggerDelegate interface {
+	Trace(msg string, kvs ...KeyVal)
+	Debug(msg string, kvs ...KeyVal)
+	Info(msg string, kvs ...KeyVal)
+	Warn(msg string, kvs ...KeyVal)
+	Error(msg string, kvs ...KeyVal)
+	Fatal(msg string, kvs ...KeyVal)
+	DPanic(msg string, kvs ...KeyVal)
+	Panic(msg string, kvs ...KeyVal)
+	// With returns a delegate adding kvs to every entry, sharing the level of the receiver.
+	With(kvs []KeyVal) LoggerDelegate
+}
+
+// CallerDelegate is implemented by the delegates reporting the caller of an entry natively,
+// e.g. as fields. When report-caller is set the Logger finds the caller once per entry,
+// the delegates not implementing it get the caller prepended to the message.
+type CallerDelegate interface {
+	// LogCaller logs an entry at level, pc is the program counter of the caller, 0 when not reported.
+	// At LvlPanic and LvlFatal it panics and exits like Panic and Fatal.
+	LogCaller(level LevelNum, pc uintptr, msg string, kvs ...KeyVal)
+}
+
+type BackendConstructor func() Backend
+
+var backends = map[string]BackendConstructor{
+	string(ZapLoggerFactoryImpl): func() Backend {
+		backend := ZapLoggerFactoryImpl
+		return &backend
+	},
+	string(LogrusLoggerFactoryImpl): func() Backend {
+		backend := LogrusLoggerFactoryImpl
+		return &backend
+	},
+	string(SlogLoggerFactoryImpl): func() Backend {
+		backend := SlogLoggerFactoryImpl
+		return &backend
+	},
+	string(ZerologLoggerFactoryImpl): func() Backend {
+		backend := ZerologLoggerFactoryImpl
+		return &backend
+	},
+}
+var backendsLk = &sync.RWMutex{}
+
+// RegisterBackend makes a backend available to NewLoggerFactory and the factory option of LoggingConfig,
+// registering an existing name replaces it.
+func RegisterBackend(name string, ctor BackendConstructor) {
+	backendsLk.Lock()
+	defer backendsLk.Unlock()
+	backends[strings.ToLower(name)] = ctor
+}
+
+func newBackend(name string) (Backend, error) {
+	backendsLk.RLock()
+	defer backendsLk.RUnlock()
+	ctor, exists := backends[strings.ToLower(name)]
+	if !exists {
+		return nil, fmt.Errorf("unknown logger factory %q", name)
+	}
+	return ctor(), nil
+}
@@ -0,0 +1,85 @@
+package factory
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingBackend keeps the messages of its delegates.
+type recordingBackend struct {
+	lk       sync.Mutex
+	messages []string
+}
+
+type recordingDelegate struct {
+	backend *recordingBackend
+	config  *LoggerConfig
+	fields  []KeyVal
+}
+
+func (b *recordingBackend) NewDelegate(config *LoggerConfig) LoggerDelegate {
+	return &recordingDelegate{backend: b, config: config}
+}
+
+func (b *recordingBackend) SetLevel(delegate LoggerDelegate, config *LoggerConfig) LoggerDelegate {
+	d := *delegate.(*recordingDelegate)
+	d.config = config
+	return &d
+}
+
+func (d *recordingDelegate) log(level string, msg string, kvs []KeyVal) {
+	d.backend.lk.Lock()
+	defer d.backend.lk.Unlock()
+	line := d.config.Name + " " + level + " " + msg
+	for _, kv := range joinKeyVals(d.fields, kvs) {
+		line += " " + kv.Key
+	}
+	d.backend.messages = append(d.backend.messages, line)
+}
+
+func (d *recordingDelegate) Trace(msg string, kvs ...KeyVal)  { d.log("trace", msg, kvs) }
+func (d *recordingDelegate) Debug(msg string, kvs ...KeyVal)  { d.log("debug", msg, kvs) }
+func (d *recordingDelegate) Info(msg string, kvs ...KeyVal)   { d.log("info", msg, kvs) }
+func (d *recordingDelegate) Warn(msg string, kvs ...KeyVal)   { d.log("warn", msg, kvs) }
+func (d *recordingDelegate) Error(msg string, kvs ...KeyVal)  { d.log("error", msg, kvs) }
+func (d *recordingDelegate) Fatal(msg string, kvs ...KeyVal)  { d.log("fatal", msg, kvs) }
+func (d *recordingDelegate) DPanic(msg string, kvs ...KeyVal) { d.log("dpanic", msg, kvs) }
+func (d *recordingDelegate) Panic(msg string, kvs ...KeyVal)  { d.log("panic", msg, kvs) }
+
+func (d *recordingDelegate) With(kvs []KeyVal) LoggerDelegate {
+	return &recordingDelegate{backend: d.backend, config: d.config, fields: joinKeyVals(d.fields, kvs)}
+}
+
+func TestRegisterBackend(t *testing.T) {
+	backend := &recordingBackend{}
+	RegisterBackend("Recording", func() Backend { return backend })
+	f, err := NewLoggerFactory("recording", func(caller string) string { return caller })
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _ := testMemoryConfig(t, "json")
+	l := f.NewPackageLogger("backend/recording", config)
+	l.Infow("hello", "k", 1)
+	l.With(KeyVal{Key: "bound", Val: 2}).Warn("child")
+	l.Debug("hidden")
+	if err := f.SetLevels("backend", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("shown")
+	want := []string{"backend/recording info hello k", "backend/recording warn child bound", "backend/recording debug shown"}
+	if strings.Join(backend.messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("messages %q, want %q", backend.messages, want)
+	}
+}
+
+func TestUnknownBackend(t *testing.T) {
+	if _, err := NewLoggerFactory("log4j", nil); err == nil || !strings.Contains(err.Error(), "log4j") {
+		t.Errorf("error %v", err)
+	}
+	for _, impl := range testBackends {
+		if _, err := NewLoggerFactory(strings.ToUpper(impl), nil); err != nil {
+			t.Errorf("%s: %v", impl, err)
+		}
+	}
+}
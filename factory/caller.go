@@ -0,0 +1,75 @@
+package factory
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// callerPC returns the program counter skip frames above the function calling callerPC, 0 when there is none.
+func callerPC(skip int) uintptr {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) == 0 {
+		return 0
+	}
+	return pcs[0]
+}
+
+// callerStack returns the stack from skip frames above the function calling callerStack,
+// one function and its file:line per frame like zap's stacktrace.
+func callerStack(skip int) string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	buf := &strings.Builder{}
+	for {
+		frame, more := frames.Next()
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(frame.Function)
+		buf.WriteString("\n\t")
+		buf.WriteString(frame.File)
+		buf.WriteByte(':')
+		buf.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			return buf.String()
+		}
+	}
+}
+
+// callerFrame resolves a pc of callerPC, nil when pc is 0.
+func callerFrame(pc uintptr) *runtime.Frame {
+	if pc == 0 {
+		return nil
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return &frame
+}
+
+// callerPrefix is the caller prepended to the messages of the delegates not implementing CallerDelegate.
+func callerPrefix(pc uintptr, name string) string {
+	frame := callerFrame(pc)
+	funcName := stringAfterLast(frame.Function, SLASH)
+	fileName := stringAfterLast(frame.File, SLASH)
+	return fmt.Sprintf("%s(%s/%s:%d)\t", funcName, name, fileName, frame.Line)
+}
+
+// shortCallerPath
+// /go/src/project/protocol/ip/tcp.go:42 => ip/tcp.go:42, like zapcore.ShortCallerEncoder.
+func shortCallerPath(frame *runtime.Frame) string {
+	file := frame.File
+	if idx := strings.LastIndex(file, SLASH); idx >= 0 {
+		if idx = strings.LastIndex(file[:idx], SLASH); idx >= 0 {
+			file = file[idx+1:]
+		}
+	}
+	return file + ":" + strconv.Itoa(frame.Line)
+}
+
+// shortFunction
+// github.com/jeevan86/project/protocol/ip.(*Conn).Read => ip.(*Conn).Read
+func shortFunction(frame *runtime.Frame) string {
+	return stringAfterLast(frame.Function, SLASH)
+}
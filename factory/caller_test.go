@@ -0,0 +1,85 @@
+package factory
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// line returns the line of its caller.
+func line() int {
+	_, _, l, _ := runtime.Caller(1)
+	return l
+}
+
+// wrapInfo is a helper wrapping the logger, the caller is the caller of wrapInfo.
+func wrapInfo(l *Logger, msg string) {
+	l.AddCallerSkip(1).Info(msg)
+}
+
+// skInfo reports the caller of skInfo, one frame above SkInfo's default.
+func skInfo(l *Logger, msg string) {
+	l.SkInfo(skCallerSkip+1, msg)
+}
+
+// callerCalls log once each and return the line expected as the caller.
+var callerCalls = []func(l *Logger) int{
+	func(l *Logger) int { l.Info("plain"); return line() },
+	func(l *Logger) int { l.Infow("structured", "k", 1); return line() },
+	func(l *Logger) int { l.InfoCtx(context.Background(), "ctx"); return line() },
+	func(l *Logger) int { l.InfoFn(func() string { return "fn" }); return line() },
+	func(l *Logger) int { l.SkInfo(skCallerSkip, "sk"); return line() },
+	func(l *Logger) int { skInfo(l, "sk helper"); return line() },
+	func(l *Logger) int { wrapInfo(l, "wrapped"); return line() },
+	func(l *Logger) int { l.With(KeyVal{Key: "c", Val: 1}).Info("child"); return line() },
+	func(l *Logger) int { l.Ctx(context.Background()).AddCallerSkip(0).Warnw("child ctx"); return line() },
+}
+
+func TestReportCaller(t *testing.T) {
+	for _, impl := range testBackends {
+		for _, formatter := range []string{"normal", "json", "pattern"} {
+			for _, reportCaller := range []bool{true, false} {
+				config, out := testMemoryConfig(t, formatter)
+				config.ReportCaller = reportCaller
+				config.Pattern = "%file:%line %msg%n"
+				l := testFactory(t, impl).NewPackageLogger("caller/"+impl, config)
+				lines := make([]int, 0, len(callerCalls))
+				for _, call := range callerCalls {
+					lines = append(lines, call(l))
+				}
+				rows := out.lines()
+				if len(rows) != len(lines) {
+					t.Fatalf("%s %s: rows %q", impl, formatter, rows)
+				}
+				for i, row := range rows {
+					// slog json reports the line as a number
+					reported := strings.Contains(row, "caller_test.go:"+strconv.Itoa(lines[i])) ||
+						strings.Contains(row, `"line":`+strconv.Itoa(lines[i])+"}")
+					if reportCaller != reported {
+						t.Errorf("%s %s report-caller %v: row %d: %s", impl, formatter, reportCaller, i, row)
+					}
+					// without report-caller the pattern layout walks the stack to the first frame
+					// outside this package, the test is skipped too
+					if !reportCaller && strings.Contains(row, "caller_test.go") {
+						t.Errorf("%s %s: caller without report-caller: %s", impl, formatter, row)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReportCallerFunction(t *testing.T) {
+	for _, impl := range testBackends {
+		config, out := testMemoryConfig(t, "json")
+		config.ReportCaller = true
+		l := testFactory(t, impl).NewPackageLogger("caller/function/"+impl, config)
+		l.Info("plain")
+		rows := out.lines()
+		if len(rows) != 1 || !strings.Contains(rows[0], "factory.TestReportCallerFunction") {
+			t.Errorf("%s: rows %q", impl, rows)
+		}
+	}
+}
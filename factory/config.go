@@ -1,16 +1,25 @@
 package factory
 
 type LoggingConfig struct {
-	Factory       string            `yaml:"factory"`
-	RootName      string            `yaml:"root-name"`
-	RootLevel     string            `yaml:"root-level"`
-	PackageLevels map[string]string `yaml:"package-levels"`
-	Formatter     string            `yaml:"formatter"`
-	Appenders     []AppenderConfig  `yaml:"appenders"`
-	ReportCaller  bool              `yaml:"report-caller"`
+	Factory         string                    `yaml:"factory"`
+	RootName        string                    `yaml:"root-name"`
+	RootLevel       string                    `yaml:"root-level"`
+	PackageLevels   map[string]string         `yaml:"package-levels"`
+	Formatter       string                    `yaml:"formatter"` // normal | json | pattern
+	Pattern         string                    `yaml:"pattern"`   // conversion pattern of the pattern formatter
+	Appenders       []AppenderConfig          `yaml:"appenders"`
+	ReportCaller    bool                      `yaml:"report-caller"`
+	ContextKeys     []string                  `yaml:"context-keys"`     // request-id | tenant-id | user-id | trace-id | span-id ...
+	StacktraceLevel string                    `yaml:"stacktrace-level"` // the entries at or above it get the stacktrace field, none when empty, none or off
+	Sampling        *SamplingConfig           `yaml:"sampling"`         // off when nil
+	PackageSampling map[string]SamplingConfig `yaml:"package-sampling"` // inherited by sub packages like package-levels
 }
 
 type AppenderConfig struct {
-	Type    string            `yaml:"type"` // stdout | file | kafka ...
-	Options map[string]string `yaml:"options"`
+	Type      string            `yaml:"type"` // stdout | stderr | file | kafka | syslog | async | dedup ...
+	Options   map[string]string `yaml:"options"`
+	Appenders []AppenderConfig  `yaml:"appenders"` // wrapped by an async or dedup appender
+	Formatter string            `yaml:"formatter"` // the formatter of LoggingConfig when empty
+	Pattern   string            `yaml:"pattern"`
+	Level     string            `yaml:"level"` // threshold of the appender, below the level of the logger it has no effect
 }
@@ -0,0 +1,76 @@
+package factory
+
+import (
+	"context"
+)
+
+// ContextKey is the key type of the values lf4go extracts from a context.Context,
+// e.g. context.WithValue(ctx, factory.CtxRequestId, "9f86d081")
+type ContextKey string
+
+const (
+	CtxRequestId ContextKey = "request-id"
+	CtxTenantId  ContextKey = "tenant-id"
+	CtxUserId    ContextKey = "user-id"
+	CtxTraceId   ContextKey = "trace-id"
+	CtxSpanId    ContextKey = "span-id"
+)
+
+// defaultContextKeys are extracted when LoggingConfig.ContextKeys is not configured.
+var defaultContextKeys = []string{
+	string(CtxRequestId),
+	string(CtxTenantId),
+	string(CtxUserId),
+	string(CtxTraceId),
+	string(CtxSpanId),
+}
+
+// ContextExtractor returns the value of a single field stored in ctx.
+type ContextExtractor func(ctx context.Context) (interface{}, bool)
+
+// RegisterContextExtractor replaces the default lookup of ctx.Value(ContextKey(name)),
+// e.g. to read the trace id from a tracing library's own context key.
+// The extractors are copied on write, contextFields reads them without locking.
+func (f *LoggerFactory) RegisterContextExtractor(name string, extractor ContextExtractor) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	extractors := make(map[string]ContextExtractor)
+	if current := f.extractors.Load(); current != nil {
+		for k, v := range *current {
+			extractors[k] = v
+		}
+	}
+	extractors[name] = extractor
+	f.extractors.Store(&extractors)
+}
+
+func (f *LoggerFactory) contextFields(ctx context.Context, names []string) []KeyVal {
+	if ctx == nil {
+		return nil
+	}
+	if names == nil {
+		names = defaultContextKeys
+	}
+	var extractors map[string]ContextExtractor
+	if current := f.extractors.Load(); current != nil {
+		extractors = *current
+	}
+	var kvs []KeyVal
+	for _, name := range names {
+		var val interface{}
+		if extractor, exists := extractors[name]; exists {
+			v, ok := extractor(ctx)
+			if !ok {
+				continue
+			}
+			val = v
+		} else {
+			val = ctx.Value(ContextKey(name))
+		}
+		if val == nil {
+			continue
+		}
+		kvs = append(kvs, KeyVal{Key: name, Val: val})
+	}
+	return MDCFrom(ctx).fields(kvs)
+}
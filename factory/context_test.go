@@ -0,0 +1,62 @@
+package factory
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContextFields(t *testing.T) {
+	for _, impl := range testBackends {
+		config, out := testMemoryConfig(t, "json")
+		f := testFactory(t, impl)
+		l := f.NewPackageLogger("ctx/"+impl, config)
+		ctx := context.WithValue(context.Background(), CtxRequestId, "r-1")
+		ctx = context.WithValue(ctx, CtxTenantId, "t-1")
+		ctx = context.WithValue(ctx, ContextKey("unknown"), "u")
+		l.InfoCtx(ctx, "default keys %d", 1)
+		l.Ctx(ctx).Warnw("child", "k", "v")
+		l.InfoCtx(nil, "nil ctx")
+
+		entries := jsonLines(t, out.lines())
+		if len(entries) != 3 {
+			t.Fatalf("%s: entries %v", impl, entries)
+		}
+		for _, e := range entries[:2] {
+			if e["request-id"] != "r-1" || e["tenant-id"] != "t-1" || e["unknown"] != nil || e["user-id"] != nil {
+				t.Errorf("%s: %v", impl, e)
+			}
+		}
+		if entries[1]["k"] != "v" || entries[2]["request-id"] != nil {
+			t.Errorf("%s: %v", impl, entries)
+		}
+	}
+}
+
+func TestContextKeysAndExtractors(t *testing.T) {
+	for _, impl := range testBackends {
+		config, out := testMemoryConfig(t, "json")
+		config.ContextKeys = []string{"trace-id", "request-id"}
+		f := testFactory(t, impl)
+		l := f.NewPackageLogger("ctx/keys/"+impl, config)
+		type spanKey struct{}
+		f.RegisterContextExtractor("trace-id", func(ctx context.Context) (interface{}, bool) {
+			span, ok := ctx.Value(spanKey{}).(string)
+			return "trace-of-" + span, ok
+		})
+		ctx := context.WithValue(context.Background(), CtxTenantId, "t-1")
+		ctx = context.WithValue(ctx, CtxRequestId, "r-1")
+		l.InfoCtx(context.WithValue(ctx, spanKey{}, "s-1"), "traced")
+		l.InfoCtx(ctx, "untraced")
+
+		entries := jsonLines(t, out.lines())
+		if len(entries) != 2 {
+			t.Fatalf("%s: entries %v", impl, entries)
+		}
+		if e := entries[0]; e["trace-id"] != "trace-of-s-1" || e["request-id"] != "r-1" || e["tenant-id"] != nil {
+			t.Errorf("%s: %v", impl, e)
+		}
+		if e := entries[1]; e["trace-id"] != nil || e["request-id"] != "r-1" {
+			t.Errorf("%s: %v", impl, e)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package factory
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	errorKey      = "error"
+	stacktraceKey = "stacktrace"
+)
+
+// stacktraceOff is the stacktrace-level when it is not set, no entry gets a stacktrace.
+const stacktraceOff = LvlFatal + 1
+
+// errorField is the value of Err, expanded to the error, error.type and error.stack fields.
+type errorField struct {
+	err error
+}
+
+// Err returns the field of err, rendered as error, error.type and error.stack,
+// nothing is rendered when err is nil:
+//
+//	logger.Errorw("payment failed", factory.Err(err), "order_id", id)
+func Err(err error) KeyVal {
+	return KeyVal{Key: errorKey, Val: errorField{err: err}}
+}
+
+// WithError returns a child logger adding the fields of Err(err) to every entry,
+// error.stack is the stack of the call to WithError.
+func (l *Logger) WithError(err error) *Logger {
+	return l.With(expandErrors([]KeyVal{Err(err)}, false, 1+l.callerSkip)...)
+}
+
+// expandErrors replaces the Err fields of kvs and adds the stacktrace field when withStack,
+// the stack is taken once, skip frames above the function calling expandErrors.
+// kvs is returned as is when there is nothing to do.
+func expandErrors(kvs []KeyVal, withStack bool, skip int) []KeyVal {
+	hasErr := false
+	for _, kv := range kvs {
+		if _, ok := kv.Val.(errorField); ok {
+			hasErr = true
+			break
+		}
+	}
+	if !hasErr && !withStack {
+		return kvs
+	}
+	stack := callerStack(skip + 1)
+	expanded := make([]KeyVal, 0, len(kvs)+3)
+	for _, kv := range kvs {
+		if field, ok := kv.Val.(errorField); ok {
+			expanded = append(expanded, errorKeyVals(kv.Key, field.err, stack)...)
+			continue
+		}
+		expanded = append(expanded, kv)
+	}
+	if withStack {
+		expanded = append(expanded, KeyVal{Key: stacktraceKey, Val: stack})
+	}
+	return expanded
+}
+
+// errorKeyVals
+// error: the message, error.type: the types of the chain, error.stack: the stack carried by
+// an error of the chain, e.g. by github.com/pkg/errors, stack otherwise.
+func errorKeyVals(key string, err error, stack string) []KeyVal {
+	if err == nil {
+		return nil
+	}
+	chain := errorChain(err, nil)
+	types := make([]string, len(chain))
+	for i, e := range chain {
+		types[i] = fmt.Sprintf("%T", e)
+		if formatter, ok := e.(fmt.Formatter); ok {
+			if verbose := fmt.Sprintf("%+v", formatter); verbose != e.Error() {
+				stack = verbose
+			}
+		}
+	}
+	return []KeyVal{
+		{Key: key, Val: err.Error()},
+		{Key: key + ".type", Val: strings.Join(types, ", ")},
+		{Key: key + ".stack", Val: stack},
+	}
+}
+
+// errorChain
+// the errors of errors.Unwrap and errors.Join, depth first.
+func errorChain(err error, chain []error) []error {
+	chain = append(chain, err)
+	switch wrapping := err.(type) {
+	case interface{ Unwrap() error }:
+		if wrapped := wrapping.Unwrap(); wrapped != nil {
+			chain = errorChain(wrapped, chain)
+		}
+		break
+	case interface{ Unwrap() []error }:
+		for _, wrapped := range wrapping.Unwrap() {
+			if wrapped != nil {
+				chain = errorChain(wrapped, chain)
+			}
+		}
+		break
+	}
+	return chain
+}
+
+// stacktraceLevel
+// the level of stacktrace-level, stacktraceOff when it is empty, none or off.
+func stacktraceLevel(level string) (LevelNum, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "", "none", "off":
+		return stacktraceOff, nil
+	}
+	if !isLevelName(strings.TrimSpace(level)) {
+		return stacktraceOff, fmt.Errorf("invalid stacktrace-level %q", level)
+	}
+	return logLevelNum(strings.TrimSpace(level)), nil
+}
@@ -0,0 +1,76 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestErrorFields(t *testing.T) {
+	_, openErr := os.Open("/does/not/exist")
+	wrapped := fmt.Errorf("load: %w", errors.Join(openErr, fs.ErrClosed))
+	for _, impl := range testBackends {
+		t.Run(impl, func(t *testing.T) {
+			config, out := testMemoryConfig(t, "json")
+			config.StacktraceLevel = "error"
+			l := testFactory(t, impl).NewPackageLogger("errors/"+impl, config)
+			l.Warnw("wrapped", Err(wrapped))
+			l.Warnw("bare", openErr)
+			l.WithError(openErr).Warn("child")
+			l.Error("plain")
+			l.Warnw("nil", Err(nil))
+
+			entries := jsonLines(t, out.lines())
+			if len(entries) != 5 {
+				t.Fatalf("entries %v", entries)
+			}
+			first := entries[0]
+			if first["error"] != wrapped.Error() {
+				t.Errorf("error %v", first["error"])
+			}
+			types, _ := first["error.type"].(string)
+			if !strings.HasPrefix(types, "*fmt.wrapError, *errors.joinError, *fs.PathError, syscall.Errno") {
+				t.Errorf("error.type %q", types)
+			}
+			if stack, _ := first["error.stack"].(string); !strings.Contains(stack, "TestErrorFields") {
+				t.Errorf("error.stack %q", stack)
+			}
+			if _, exists := first[stacktraceKey]; exists {
+				t.Error("stacktrace below stacktrace-level")
+			}
+			for _, e := range entries[1:3] {
+				if e["error"] != openErr.Error() || e["error.stack"] == nil {
+					t.Errorf("error fields of %v", e)
+				}
+			}
+			if stack, _ := entries[3][stacktraceKey].(string); !strings.Contains(stack, "TestErrorFields") {
+				t.Errorf("stacktrace %q", stack)
+			}
+			if _, exists := entries[4]["error"]; exists {
+				t.Errorf("nil error rendered: %v", entries[4])
+			}
+		})
+	}
+}
+
+func TestStacktraceLevel(t *testing.T) {
+	for level, want := range map[string]LevelNum{"": stacktraceOff, "none": stacktraceOff, "OFF": stacktraceOff, "warn": LvlWarn, "ERROR": LvlError} {
+		if got, err := stacktraceLevel(level); err != nil || got != want {
+			t.Errorf("stacktraceLevel(%q) = %d, %v, want %d", level, got, err, want)
+		}
+	}
+	if _, err := stacktraceLevel("eror"); err == nil {
+		t.Error("invalid stacktrace-level accepted")
+	}
+	config, _ := testMemoryConfig(t, "json")
+	f := testFactory(t, "zap")
+	f.NewPackageLogger("errors/reload", config)
+	invalid := *config
+	invalid.StacktraceLevel = "eror"
+	if err := f.Reload(&invalid); err == nil || !strings.Contains(err.Error(), "stacktrace-level") {
+		t.Errorf("reload error %v", err)
+	}
+}
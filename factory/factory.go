@@ -1,7 +1,11 @@
 package factory
 
 import (
+	"fmt"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -10,7 +14,13 @@ const (
 
 type LoggerFactory struct {
 	callerPackage func(caller string) string
-	delegate      internalFactory
+	delegate      Backend
+	extractors    atomic.Pointer[map[string]ContextExtractor] // copied on write under lk
+	lk            sync.Mutex                                  // guards config, levels, overrides and the writes of extractors
+	config        *LoggingConfig                              // set by Reload
+	levels        []*levelOverride                            // set by SetLevels, oldest first
+	overrides     map[string]*levelOverride                   // set by SetLevelsFor, by prefix
+	overrideSeq   uint64
 }
 
 type LevelName string
@@ -44,68 +54,172 @@ type KeyVal struct {
 	Val interface{}
 }
 
-type internalFactory interface {
-	getLevels(string) map[string]string
-	setLevels(string, string)
-	newLogger(config *LoggerConfig) *Logger
+func (f *LoggerFactory) GetLevels(prefix string) map[string]string {
+	levels := make(map[string]string, 16)
+	for _, logger := range factoryLoggers(f) {
+		if matchesPrefix(prefix, logger.Config.Name) {
+			levels[logger.Config.Name] = logLevelName(logger.GetConfig().Level)
+		}
+	}
+	return levels
 }
 
-func (f *LoggerFactory) GetLevels(prefix string) map[string]string {
-	return f.delegate.getLevels(prefix)
+// SetLevels
+// the level also applies to the loggers matching prefix created later,
+// the overrides of SetLevelsFor covered by prefix are cancelled.
+func (f *LoggerFactory) SetLevels(prefix string, level string) error {
+	if !isLevelName(level) {
+		return fmt.Errorf("invalid level %q", level)
+	}
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.cancelOverrides(prefix)
+	levelNum := logLevelNum(level)
+	f.rememberLevel(prefix, levelNum)
+	for _, logger := range factoryLoggers(f) {
+		if matchesPrefix(prefix, logger.Config.Name) {
+			f.setLevel(logger, levelNum)
+		}
+	}
+	return nil
+}
+
+// setLevel swaps the config and the delegate of logger,
+// must be called with f.lk held.
+func (f *LoggerFactory) setLevel(logger *Logger, level LevelNum) {
+	current := logger.state.Load()
+	config := *current.config
+	config.Level = level
+	logger.state.Store(&loggerState{
+		config:   &config,
+		delegate: f.delegate.SetLevel(current.delegate, &config),
+	})
 }
-func (f *LoggerFactory) SetLevels(prefix string, level string) {
-	f.delegate.setLevels(prefix, level)
+
+// matchesPrefix
+// "ROOT" and "" match every logger, otherwise the package prefix and its sub packages:
+// protocol/ip matches protocol/ip/tcp but not protocol/ipv6.
+func matchesPrefix(prefix string, name string) bool {
+	return "ROOT" == strings.ToUpper(prefix) || "" == prefix || isParentPackage(prefix, name)
 }
 
 func (f *LoggerFactory) NewLogger(callerFile string, config *LoggingConfig) *Logger {
-	callerPackage := f.callerPackage(callerFile)
-	level := "info"          // default level info
-	level = config.RootLevel // root level
-	if config.PackageLevels != nil {
-		level, _ = config.PackageLevels[callerPackage] // special level
+	return f.NewPackageLogger(f.callerPackage(callerFile), config)
+}
+
+// NewLoggerE is NewLogger returning the error of an invalid config.
+func (f *LoggerFactory) NewLoggerE(callerFile string, config *LoggingConfig) (*Logger, error) {
+	return f.NewPackageLoggerE(f.callerPackage(callerFile), config)
+}
+
+// NewPackageLogger exits when the config is invalid, see NewPackageLoggerE.
+func (f *LoggerFactory) NewPackageLogger(callerPackage string, config *LoggingConfig) *Logger {
+	logger, err := f.NewPackageLoggerE(callerPackage, config)
+	if err != nil {
+		fatal(err)
 	}
-	loggerConfig := &LoggerConfig{
-		Name:         callerPackage,
-		Level:        logLevelNum(level),
-		Formatter:    config.Formatter,
-		Appenders:    config.Appenders,
-		ReportCaller: config.ReportCaller,
+	return logger
+}
+
+// NewPackageLoggerE returns the error of an invalid config, e.g. an unknown appender type.
+func (f *LoggerFactory) NewPackageLoggerE(callerPackage string, config *LoggingConfig) (*Logger, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	if f.config != nil {
+		config = f.config
 	}
-	logger := f.delegate.newLogger(loggerConfig)
-	logger.factory = f
-	loggers[logger.Config.Name] = logger
-	return loggers[logger.Config.Name]
+	loggerConfig, err := newLoggerConfig(f.delegate, callerPackage, config)
+	if err != nil {
+		return nil, fmt.Errorf("logger %s: %w", callerPackage, err)
+	}
+	configured := loggerConfig.Level
+	loggerConfig.Level = f.levelOf(callerPackage, configured)
+	logger := newLogger(f, loggerConfig, f.delegate.NewDelegate(loggerConfig))
+	logger.configured = configured
+	registerLogger(logger)
+	return logger, nil
 }
 
-func (f *LoggerFactory) NewPackageLogger(callerPackage string, config *LoggingConfig) *Logger {
-	level := "info"          // default level info
-	level = config.RootLevel // root level
-	if config.PackageLevels != nil {
-		level, _ = config.PackageLevels[callerPackage] // special level
+// newLoggerConfig resolves the level, the sampling and the appenders of a logger,
+// the entries are encoded by backend.
+func newLoggerConfig(backend Backend, callerPackage string, config *LoggingConfig) (*LoggerConfig, error) {
+	level := packageLevel(callerPackage, config)
+	sampler, err := newSampler(packageSampling(callerPackage, config))
+	if err != nil {
+		return nil, err
+	}
+	stacktrace, err := stacktraceLevel(config.StacktraceLevel)
+	if err != nil {
+		return nil, err
+	}
+	out, outputs, err := writers(config)
+	if err != nil {
+		return nil, err
 	}
 	loggerConfig := &LoggerConfig{
-		Name:         callerPackage,
-		Level:        logLevelNum(level),
-		Formatter:    config.Formatter,
-		Appenders:    config.Appenders,
-		ReportCaller: config.ReportCaller,
+		Name:            callerPackage,
+		Level:           logLevelNum(level),
+		Formatter:       config.Formatter,
+		Pattern:         config.Pattern,
+		Appenders:       config.Appenders,
+		ReportCaller:    config.ReportCaller,
+		ContextKeys:     config.ContextKeys,
+		StacktraceLevel: stacktrace,
+		Writer:          out,
+		Outputs:         outputs,
+		sampler:         sampler,
+	}
+	bindEntryEncoders(backend, loggerConfig)
+	return loggerConfig, nil
+}
+
+// packageLevel
+// the level of the longest package-levels entry being callerPackage or a parent of it,
+// "protocol/ip" applies to "protocol/ip/tcp" but not to "protocol/ipv6", root-level otherwise.
+func packageLevel(callerPackage string, config *LoggingConfig) string {
+	level := "info" // default level info
+	if "" != config.RootLevel {
+		level = config.RootLevel // root level
+	}
+	matched := -1
+	for pkg, pkgLevel := range config.PackageLevels {
+		if len(pkg) > matched && isParentPackage(pkg, callerPackage) {
+			level = pkgLevel // special level
+			matched = len(pkg)
+		}
 	}
-	logger := f.delegate.newLogger(loggerConfig)
-	logger.factory = f
-	loggers[logger.Config.Name] = logger
-	return loggers[logger.Config.Name]
+	return level
+}
+
+func isParentPackage(parent string, pkg string) bool {
+	parent = strings.TrimSuffix(parent, "/")
+	return parent == pkg || strings.HasPrefix(pkg, parent+"/")
 }
 
 var ZapLoggerFactoryImpl = ZapLoggerFactory("zap")
 var LogrusLoggerFactoryImpl = LogrusLoggerFactory("logrus")
+var SlogLoggerFactoryImpl = SlogLoggerFactory("slog")
+var ZerologLoggerFactoryImpl = ZerologLoggerFactory("zerolog")
+
+// NewLoggerFactory
+// impl is the name of a registered backend: zap | logrus | slog | zerolog, see RegisterBackend
+func NewLoggerFactory(impl string, callerPackageDetector func(caller string) string) (*LoggerFactory, error) {
+	backend, err := newBackend(impl)
+	if err != nil {
+		return nil, err
+	}
+	return newLoggerFactory(callerPackageDetector, backend), nil
+}
+
+// fatal reports a configuration error that leaves logging unusable.
+func fatal(err error) {
+	fmt.Println(fmt.Sprintf("Fatal! %s", err.Error()))
+	os.Exit(-1)
+}
 
-func NewLoggerFactory(impl string, callerPackageDetector func(caller string) string) *LoggerFactory {
-	switch strings.ToLower(impl) {
-	case string(ZapLoggerFactoryImpl):
-		return ZapLoggerFactoryImpl.NewFactory(callerPackageDetector)
-	case string(LogrusLoggerFactoryImpl):
-		return LogrusLoggerFactoryImpl.NewFactory(callerPackageDetector)
-	default:
-		return LogrusLoggerFactoryImpl.NewFactory(callerPackageDetector)
+func newLoggerFactory(callerPackageDetector func(caller string) string, backend Backend) *LoggerFactory {
+	return &LoggerFactory{
+		callerPackage: callerPackageDetector,
+		delegate:      backend,
 	}
 }
@@ -0,0 +1,200 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testBackends = []string{"zap", "logrus", "slog", "zerolog"}
+
+func testFactory(t *testing.T, impl string) *LoggerFactory {
+	t.Helper()
+	f, err := NewLoggerFactory(impl, func(caller string) string { return caller })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func testFileConfig(dir string, name string) *LoggingConfig {
+	return &LoggingConfig{
+		RootLevel: "info",
+		Appenders: []AppenderConfig{{Type: "file", Options: map[string]string{"log-file-dir": dir, "log-file-name": name}}},
+	}
+}
+
+// memoryAppender keeps the entries written to it, appenders of type memory with the same id are shared.
+type memoryAppender struct {
+	lk     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+var memoryAppenders = &sync.Map{}
+var memorySeq = &atomic.Int64{}
+
+func init() {
+	RegisterAppender("memory", func(options map[string]string) (Appender, error) {
+		a, _ := memoryAppenders.LoadOrStore(options["id"], &memoryAppender{})
+		return a.(*memoryAppender), nil
+	})
+}
+
+func (a *memoryAppender) Write(p []byte) (int, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.buf.Write(p)
+}
+
+func (a *memoryAppender) Flush() error {
+	return nil
+}
+
+func (a *memoryAppender) Close() error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	a.closed = true
+	return nil
+}
+
+func (a *memoryAppender) Name() string {
+	return "memory"
+}
+
+func (a *memoryAppender) String() string {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.buf.String()
+}
+
+// lines returns the lines written and forgets them.
+func (a *memoryAppender) lines() []string {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	s := strings.TrimRight(a.buf.String(), "\n")
+	a.buf.Reset()
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// testMemoryConfig returns a config writing to a memory appender of its own.
+func testMemoryConfig(t *testing.T, formatter string) (*LoggingConfig, *memoryAppender) {
+	t.Helper()
+	id := fmt.Sprintf("%s-%d", t.Name(), memorySeq.Add(1))
+	config := &LoggingConfig{
+		RootLevel: "info",
+		Formatter: formatter,
+		Appenders: []AppenderConfig{{Type: "memory", Options: map[string]string{"id": id}}},
+	}
+	appendersLk.Lock()
+	appender, err := newAppender(config.Appenders[0])
+	appendersLk.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config, appender.(*memoryAppender)
+}
+
+func jsonLines(t *testing.T, lines []string) []map[string]interface{} {
+	t.Helper()
+	entries := make([]map[string]interface{}, 0, len(lines))
+	for _, line := range lines {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		entries = append(entries, fields)
+	}
+	return entries
+}
+
+// jsonMessage returns the message of a json entry, zerolog names it message.
+func jsonMessage(entry map[string]interface{}) interface{} {
+	if msg, exists := entry["msg"]; exists {
+		return msg
+	}
+	return entry["message"]
+}
+
+// TestConcurrentLevelsAndLogging is meant for go test -race: levels, overrides, reloads and
+// context extractors change while the loggers and their children log.
+func TestConcurrentLevelsAndLogging(t *testing.T) {
+	for _, impl := range testBackends {
+		t.Run(impl, func(t *testing.T) {
+			f := testFactory(t, impl)
+			config := testFileConfig(t.TempDir(), "race.log")
+			root := f.NewPackageLogger("race/"+impl, config)
+			stop := make(chan struct{})
+			wg := &sync.WaitGroup{}
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					child := root.With(KeyVal{Key: "goroutine", Val: i})
+					ctx := context.WithValue(context.Background(), CtxRequestId, "r-1")
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						root.Info("info %d", i)
+						child.Debug("debug")
+						child.InfoCtx(ctx, "ctx")
+						root.With(KeyVal{Key: "k", Val: 1}).Warnw("warn", "i", i)
+						_ = root.IsDebugEnabled()
+					}
+				}(i)
+			}
+			for i := 0; i < 50; i++ {
+				if err := f.SetLevels("race", []string{"debug", "info", "warn"}[i%3]); err != nil {
+					t.Error(err)
+				}
+				if err := f.SetLevelsFor("race/"+impl, "trace", time.Millisecond); err != nil {
+					t.Error(err)
+				}
+				f.RegisterContextExtractor("trace-id", func(ctx context.Context) (interface{}, bool) { return "t-1", true })
+				f.NewPackageLogger(fmt.Sprintf("race/%s/%d", impl, i), config)
+				_ = f.GetLevels("")
+				_ = f.GetLevelOverrides("")
+				if i%10 == 0 {
+					if err := f.Reload(config); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+			close(stop)
+			wg.Wait()
+
+			_ = f.SetLevels("race", "warn")
+			for name, level := range f.GetLevels("race/" + impl) {
+				if "Warn" != level {
+					t.Errorf("%s level %s, want Warn", name, level)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryKeepsLoggersOfFactories(t *testing.T) {
+	f1 := testFactory(t, "zap")
+	f2 := testFactory(t, "zap")
+	config := testFileConfig(t.TempDir(), "registry.log")
+	f1.NewPackageLogger("registry/one", config)
+	f2.NewPackageLogger("registry/two", config)
+	if levels := f1.GetLevels("registry"); len(levels) != 1 || levels["registry/one"] == "" {
+		t.Errorf("f1 levels %v", levels)
+	}
+	if levels := f2.GetLevels("registry"); len(levels) != 1 || levels["registry/two"] == "" {
+		t.Errorf("f2 levels %v", levels)
+	}
+}
@@ -1,149 +1,558 @@
 package factory
 
 import (
+	"context"
 	"fmt"
-	"runtime"
+	"io"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// loggers are the loggers by name, read with factoryLoggers.
 var loggers = make(map[string]*Logger)
+var loggersLk = &sync.RWMutex{}
 
 type Logger struct {
-	Config   *LoggerConfig
-	delegate loggerDelegate
-	factory  *LoggerFactory
+	Config  *LoggerConfig // the config the logger was created with, GetConfig returns the current one
+	factory *LoggerFactory
+	parent  *Logger  // set on loggers derived by With
+	fields  []KeyVal // fields bound by With
+	// callerSkip is the number of frames between the caller and the log methods, see AddCallerSkip
+	callerSkip int
+
+	state   atomic.Pointer[loggerState]     // replaced by SetLevels and Reload, unused by children
+	derived atomic.Pointer[derivedDelegate] // delegate of a child logger
+
+	configured LevelNum // level of the config, restored when an override of SetLevelsFor expires
 }
 
-type LoggerConfig struct {
-	Name         string
-	Level        LevelNum
-	Formatter    string
-	Appenders    []AppenderConfig
-	ReportCaller bool
+// loggerState is swapped as a whole, so that a log call never sees a config and a delegate not matching.
+type loggerState struct {
+	config   *LoggerConfig
+	delegate LoggerDelegate
 }
 
-type loggerDelegate interface {
-	Trace(string)
-	Debug(string)
-	Info(string)
-	Warn(string)
-	Error(string)
-	Fatal(string)
-	DPanic(string)
-	Panic(string)
+// derivedDelegate is the delegate of a child logger and the parent delegate it was derived from.
+type derivedDelegate struct {
+	base     LoggerDelegate
+	delegate LoggerDelegate
 }
 
-func (l *Logger) SetLevels(prefix string, level string) {
-	l.factory.SetLevels(prefix, level)
+func newLogger(f *LoggerFactory, config *LoggerConfig, delegate LoggerDelegate) *Logger {
+	logger := &Logger{
+		Config:  config,
+		factory: f,
+	}
+	logger.state.Store(&loggerState{config: config, delegate: delegate})
+	return logger
+}
+
+// registerLogger adds logger to loggers, replacing the logger with the same name.
+func registerLogger(logger *Logger) {
+	loggersLk.Lock()
+	defer loggersLk.Unlock()
+	loggers[logger.Config.Name] = logger
+}
+
+// factoryLoggers returns the loggers of f, every logger when f is nil.
+func factoryLoggers(f *LoggerFactory) []*Logger {
+	loggersLk.RLock()
+	defer loggersLk.RUnlock()
+	result := make([]*Logger, 0, len(loggers))
+	for _, logger := range loggers {
+		if f == nil || logger.factory == f {
+			result = append(result, logger)
+		}
+	}
+	return result
+}
+
+type LoggerConfig struct {
+	Name            string
+	Level           LevelNum
+	Formatter       string
+	Pattern         string
+	Appenders       []AppenderConfig
+	ReportCaller    bool
+	ContextKeys     []string
+	StacktraceLevel LevelNum       // the entries at or above it get the stacktrace field
+	sampler         *sampler       // nil when sampling is off, shared by the configs of a logger until Reload
+	Writer          io.Writer      // the appenders merged, backends unaware of Outputs write encoded entries to it
+	Outputs         []LoggerOutput // the appenders grouped by formatter and level
+}
+
+func (l *Logger) SetLevels(prefix string, level string) error {
+	return l.factory.SetLevels(prefix, level)
 }
 func (l *Logger) GetLevels(prefix string) map[string]string {
 	return l.factory.GetLevels(prefix)
 }
+func (l *Logger) SetLevelsFor(prefix string, level string, ttl time.Duration) error {
+	return l.factory.SetLevelsFor(prefix, level, ttl)
+}
+func (l *Logger) GetLevelOverrides(prefix string) []LevelOverride {
+	return l.factory.GetLevelOverrides(prefix)
+}
+
+// With returns a child logger adding kvs to every entry.
+// The child shares the level of l and is not registered in loggers.
+func (l *Logger) With(kvs ...KeyVal) *Logger {
+	kvs = expandErrors(kvs, false, 1+l.callerSkip)
+	root := l
+	fields := kvs
+	if l.parent != nil {
+		root = l.parent
+		fields = make([]KeyVal, 0, len(l.fields)+len(kvs))
+		fields = append(fields, l.fields...)
+		fields = append(fields, kvs...)
+	}
+	return &Logger{
+		Config:     root.Config,
+		factory:    root.factory,
+		parent:     root,
+		fields:     fields,
+		callerSkip: l.callerSkip,
+	}
+}
+
+// AddCallerSkip returns a child logger reporting the caller skip more frames above its log methods,
+// e.g. 1 for a helper wrapping the logger.
+func (l *Logger) AddCallerSkip(skip int) *Logger {
+	child := l.With()
+	child.callerSkip += skip
+	return child
+}
+
+// Ctx returns a child logger carrying the fields extracted from ctx.
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	return l.With(l.factory.contextFields(ctx, l.GetConfig().ContextKeys)...)
+}
+
+// GetConfig returns the current config of the logger, it must not be modified.
+func (l *Logger) GetConfig() *LoggerConfig {
+	if l.parent != nil {
+		return l.parent.state.Load().config
+	}
+	return l.state.Load().config
+}
+
+// getDelegate re-derives the delegate of a child logger whenever
+// the parent delegate has been replaced, e.g. by SetLevels.
+func (l *Logger) getDelegate() LoggerDelegate {
+	if l.parent == nil {
+		return l.state.Load().delegate
+	}
+	base := l.parent.state.Load().delegate
+	derived := l.derived.Load()
+	if derived == nil || derived.base != base {
+		// concurrent callers may derive it twice, the last one is kept
+		derived = &derivedDelegate{base: base, delegate: base.With(l.fields)}
+		l.derived.Store(derived)
+	}
+	return derived.delegate
+}
+
+// enabled reports whether the entries at level pass the level of the logger,
+// log methods check it before formatting anything.
+func (l *Logger) enabled(level LevelNum) bool {
+	return l.GetConfig().Level <= level
+}
+
+// admit reports whether an entry at level with the message template passes the level
+// and the sampling of the logger, log methods check it before formatting anything.
+func (l *Logger) admit(level LevelNum, template string) bool {
+	config := l.GetConfig()
+	return config.Level <= level && config.sampler.allow(level, template)
+}
+
+// sampled reports whether an entry at level with msg passes the sampling of the logger.
+func (l *Logger) sampled(level LevelNum, msg string) bool {
+	return l.GetConfig().sampler.allow(level, msg)
+}
 
 func (l *Logger) IsTraceEnabled() bool {
-	return l.Config.Level <= LvlTrace
+	return l.enabled(LvlTrace)
 }
 func (l *Logger) IsDebugEnabled() bool {
-	return l.Config.Level <= LvlDebug
+	return l.enabled(LvlDebug)
 }
 func (l *Logger) IsInfoEnabled() bool {
-	return l.Config.Level <= LvlInfo
+	return l.enabled(LvlInfo)
 }
 func (l *Logger) IsWarnEnabled() bool {
-	return l.Config.Level <= LvlWarn
+	return l.enabled(LvlWarn)
 }
 func (l *Logger) IsErrorEnabled() bool {
-	return l.Config.Level <= LvlError
+	return l.enabled(LvlError)
 }
 func (l *Logger) IsDPanicEnabled() bool {
-	return l.Config.Level <= LvlDPanic
+	return l.enabled(LvlDPanic)
 }
 func (l *Logger) IsPanicEnabled() bool {
-	return l.Config.Level <= LvlPanic
+	return l.enabled(LvlPanic)
 }
 func (l *Logger) IsFatalEnabled() bool {
-	return l.Config.Level <= LvlFatal
+	return l.enabled(LvlFatal)
 }
 
 func (l *Logger) Trace(format string, args ...interface{}) {
-	l.delegate.Trace(l.doFormat(format, args...))
+	if !l.admit(LvlTrace, format) {
+		return
+	}
+	l.log(LvlTrace, 0, sprintf(format, args), nil)
 }
 func (l *Logger) Debug(format string, args ...interface{}) {
-	l.delegate.Debug(l.doFormat(format, args...))
+	if !l.admit(LvlDebug, format) {
+		return
+	}
+	l.log(LvlDebug, 0, sprintf(format, args), nil)
 }
 func (l *Logger) Info(format string, args ...interface{}) {
-	l.delegate.Info(l.doFormat(format, args...))
+	if !l.admit(LvlInfo, format) {
+		return
+	}
+	l.log(LvlInfo, 0, sprintf(format, args), nil)
 }
 func (l *Logger) Warn(format string, args ...interface{}) {
-	l.delegate.Warn(l.doFormat(format, args...))
+	if !l.admit(LvlWarn, format) {
+		return
+	}
+	l.log(LvlWarn, 0, sprintf(format, args), nil)
 }
 func (l *Logger) Error(format string, args ...interface{}) {
-	l.delegate.Error(l.doFormat(format, args...))
+	if !l.admit(LvlError, format) {
+		return
+	}
+	l.log(LvlError, 0, sprintf(format, args), nil)
 }
 func (l *Logger) DPanic(format string, args ...interface{}) {
-	l.delegate.DPanic(l.doFormat(format, args...))
+	if !l.admit(LvlDPanic, format) {
+		return
+	}
+	l.log(LvlDPanic, 0, sprintf(format, args), nil)
 }
 func (l *Logger) Panic(format string, args ...interface{}) {
-	l.delegate.Panic(l.doFormat(format, args...))
+	l.log(LvlPanic, 0, sprintf(format, args), nil)
 }
 func (l *Logger) Fatal(format string, args ...interface{}) {
-	l.delegate.Fatal(l.doFormat(format, args...))
+	l.log(LvlFatal, 0, sprintf(format, args), nil)
+}
+
+// TraceFn logs the message returned by fn, fn is only called when the level is enabled.
+func (l *Logger) TraceFn(fn func() string) {
+	if !l.enabled(LvlTrace) {
+		return
+	}
+	msg := fn()
+	if !l.sampled(LvlTrace, msg) {
+		return
+	}
+	l.log(LvlTrace, 0, msg, nil)
+}
+func (l *Logger) DebugFn(fn func() string) {
+	if !l.enabled(LvlDebug) {
+		return
+	}
+	msg := fn()
+	if !l.sampled(LvlDebug, msg) {
+		return
+	}
+	l.log(LvlDebug, 0, msg, nil)
+}
+func (l *Logger) InfoFn(fn func() string) {
+	if !l.enabled(LvlInfo) {
+		return
+	}
+	msg := fn()
+	if !l.sampled(LvlInfo, msg) {
+		return
+	}
+	l.log(LvlInfo, 0, msg, nil)
+}
+func (l *Logger) WarnFn(fn func() string) {
+	if !l.enabled(LvlWarn) {
+		return
+	}
+	msg := fn()
+	if !l.sampled(LvlWarn, msg) {
+		return
+	}
+	l.log(LvlWarn, 0, msg, nil)
+}
+func (l *Logger) ErrorFn(fn func() string) {
+	if !l.enabled(LvlError) {
+		return
+	}
+	msg := fn()
+	if !l.sampled(LvlError, msg) {
+		return
+	}
+	l.log(LvlError, 0, msg, nil)
 }
 
-func (l *Logger) doFormat(format string, args ...interface{}) string {
-	msg := format
-	if args != nil && len(args) != 0 {
-		msg = fmt.Sprintf(format, args...)
+// LazyArg is an argument of a log method computed only when the entry is logged,
+// logger.Debug("state %s", factory.LazyArg(func() interface{} { return dump(state) })).
+// It can also be the value of a field of the *w methods.
+type LazyArg func() interface{}
+
+// resolveLazyArgs returns args with the LazyArg elements replaced by their value.
+func resolveLazyArgs(args []interface{}) []interface{} {
+	var resolved []interface{}
+	for i, arg := range args {
+		if lazy, ok := arg.(LazyArg); ok {
+			if resolved == nil {
+				resolved = make([]interface{}, len(args))
+				copy(resolved, args)
+			}
+			resolved[i] = lazy()
+		}
+	}
+	if resolved == nil {
+		return args
 	}
-	if l.Config.ReportCaller {
-		msg = l.withCaller(3, msg)
+	return resolved
+}
+
+// sprintf formats the message of the printf style methods.
+func sprintf(format string, args []interface{}) string {
+	if len(args) == 0 {
+		return format
 	}
-	return msg
+	return fmt.Sprintf(format, resolveLazyArgs(args)...)
 }
 
+// Tracew logs msg with structured fields, kvs are KeyVal elements or alternating key/value pairs.
+func (l *Logger) Tracew(msg string, kvs ...interface{}) {
+	if !l.admit(LvlTrace, msg) {
+		return
+	}
+	l.log(LvlTrace, 0, msg, keyVals(kvs...))
+}
+func (l *Logger) Debugw(msg string, kvs ...interface{}) {
+	if !l.admit(LvlDebug, msg) {
+		return
+	}
+	l.log(LvlDebug, 0, msg, keyVals(kvs...))
+}
+func (l *Logger) Infow(msg string, kvs ...interface{}) {
+	if !l.admit(LvlInfo, msg) {
+		return
+	}
+	l.log(LvlInfo, 0, msg, keyVals(kvs...))
+}
+func (l *Logger) Warnw(msg string, kvs ...interface{}) {
+	if !l.admit(LvlWarn, msg) {
+		return
+	}
+	l.log(LvlWarn, 0, msg, keyVals(kvs...))
+}
+func (l *Logger) Errorw(msg string, kvs ...interface{}) {
+	if !l.admit(LvlError, msg) {
+		return
+	}
+	l.log(LvlError, 0, msg, keyVals(kvs...))
+}
+func (l *Logger) DPanicw(msg string, kvs ...interface{}) {
+	if !l.admit(LvlDPanic, msg) {
+		return
+	}
+	l.log(LvlDPanic, 0, msg, keyVals(kvs...))
+}
+func (l *Logger) Panicw(msg string, kvs ...interface{}) {
+	l.log(LvlPanic, 0, msg, keyVals(kvs...))
+}
+func (l *Logger) Fatalw(msg string, kvs ...interface{}) {
+	l.log(LvlFatal, 0, msg, keyVals(kvs...))
+}
+
+func (l *Logger) TraceCtx(ctx context.Context, format string, args ...interface{}) {
+	if !l.admit(LvlTrace, format) {
+		return
+	}
+	l.log(LvlTrace, 0, sprintf(format, args), l.factory.contextFields(ctx, l.GetConfig().ContextKeys))
+}
+func (l *Logger) DebugCtx(ctx context.Context, format string, args ...interface{}) {
+	if !l.admit(LvlDebug, format) {
+		return
+	}
+	l.log(LvlDebug, 0, sprintf(format, args), l.factory.contextFields(ctx, l.GetConfig().ContextKeys))
+}
+func (l *Logger) InfoCtx(ctx context.Context, format string, args ...interface{}) {
+	if !l.admit(LvlInfo, format) {
+		return
+	}
+	l.log(LvlInfo, 0, sprintf(format, args), l.factory.contextFields(ctx, l.GetConfig().ContextKeys))
+}
+func (l *Logger) WarnCtx(ctx context.Context, format string, args ...interface{}) {
+	if !l.admit(LvlWarn, format) {
+		return
+	}
+	l.log(LvlWarn, 0, sprintf(format, args), l.factory.contextFields(ctx, l.GetConfig().ContextKeys))
+}
+func (l *Logger) ErrorCtx(ctx context.Context, format string, args ...interface{}) {
+	if !l.admit(LvlError, format) {
+		return
+	}
+	l.log(LvlError, 0, sprintf(format, args), l.factory.contextFields(ctx, l.GetConfig().ContextKeys))
+}
+func (l *Logger) DPanicCtx(ctx context.Context, format string, args ...interface{}) {
+	if !l.admit(LvlDPanic, format) {
+		return
+	}
+	l.log(LvlDPanic, 0, sprintf(format, args), l.factory.contextFields(ctx, l.GetConfig().ContextKeys))
+}
+func (l *Logger) PanicCtx(ctx context.Context, format string, args ...interface{}) {
+	l.log(LvlPanic, 0, sprintf(format, args), l.factory.contextFields(ctx, l.GetConfig().ContextKeys))
+}
+func (l *Logger) FatalCtx(ctx context.Context, format string, args ...interface{}) {
+	l.log(LvlFatal, 0, sprintf(format, args), l.factory.contextFields(ctx, l.GetConfig().ContextKeys))
+}
+
+// SkTrace reports the caller skip frames above itself, 3 is the caller of SkTrace.
 func (l *Logger) SkTrace(skip int, format string, args ...interface{}) {
-	l.delegate.Trace(l.skDoFormat(skip, format, args...))
+	if !l.admit(LvlTrace, format) {
+		return
+	}
+	l.log(LvlTrace, skip-skCallerSkip, sprintf(format, args), nil)
 }
 func (l *Logger) SkDebug(skip int, format string, args ...interface{}) {
-	l.delegate.Debug(l.skDoFormat(skip, format, args...))
+	if !l.admit(LvlDebug, format) {
+		return
+	}
+	l.log(LvlDebug, skip-skCallerSkip, sprintf(format, args), nil)
 }
 func (l *Logger) SkInfo(skip int, format string, args ...interface{}) {
-	l.delegate.Info(l.skDoFormat(skip, format, args...))
+	if !l.admit(LvlInfo, format) {
+		return
+	}
+	l.log(LvlInfo, skip-skCallerSkip, sprintf(format, args), nil)
 }
 func (l *Logger) SkWarn(skip int, format string, args ...interface{}) {
-	l.delegate.Warn(l.skDoFormat(skip, format, args...))
+	if !l.admit(LvlWarn, format) {
+		return
+	}
+	l.log(LvlWarn, skip-skCallerSkip, sprintf(format, args), nil)
 }
 func (l *Logger) SkError(skip int, format string, args ...interface{}) {
-	l.delegate.Error(l.skDoFormat(skip, format, args...))
+	if !l.admit(LvlError, format) {
+		return
+	}
+	l.log(LvlError, skip-skCallerSkip, sprintf(format, args), nil)
 }
 func (l *Logger) SkDPanic(skip int, format string, args ...interface{}) {
-	l.delegate.DPanic(l.skDoFormat(skip, format, args...))
+	if !l.admit(LvlDPanic, format) {
+		return
+	}
+	l.log(LvlDPanic, skip-skCallerSkip, sprintf(format, args), nil)
 }
 func (l *Logger) SkPanic(skip int, format string, args ...interface{}) {
-	l.delegate.Panic(l.skDoFormat(skip, format, args...))
+	l.log(LvlPanic, skip-skCallerSkip, sprintf(format, args), nil)
 }
 func (l *Logger) SkFatal(skip int, format string, args ...interface{}) {
-	l.delegate.Fatal(l.skDoFormat(skip, format, args...))
+	l.log(LvlFatal, skip-skCallerSkip, sprintf(format, args), nil)
+}
+
+// skCallerSkip is the skip of the Sk methods reporting their own caller.
+const skCallerSkip = 3
+
+// loggerCallerSkip is the number of frames between log and the caller of a log method.
+const loggerCallerSkip = 2
+
+// log hands an entry to the delegate, skip is the number of frames added between
+// the caller and the log method calling log, e.g. by the Sk methods.
+// The caller is only looked up when report-caller is set, once per entry,
+// the stack only for the Err fields and the levels at or above stacktrace-level.
+func (l *Logger) log(level LevelNum, skip int, msg string, kvs []KeyVal) {
+	delegate := l.getDelegate()
+	config := l.GetConfig()
+	kvs = expandErrors(kvs, level >= config.StacktraceLevel, loggerCallerSkip+skip+l.callerSkip)
+	var pc uintptr
+	if config.ReportCaller {
+		pc = callerPC(loggerCallerSkip + skip + l.callerSkip)
+	}
+	if native, ok := delegate.(CallerDelegate); ok {
+		native.LogCaller(level, pc, msg, kvs...)
+		return
+	}
+	if pc != 0 {
+		msg = callerPrefix(pc, l.Config.Name) + msg
+	}
+	switch level {
+	case LvlTrace:
+		delegate.Trace(msg, kvs...)
+		break
+	case LvlDebug:
+		delegate.Debug(msg, kvs...)
+		break
+	case LvlInfo:
+		delegate.Info(msg, kvs...)
+		break
+	case LvlWarn:
+		delegate.Warn(msg, kvs...)
+		break
+	case LvlError:
+		delegate.Error(msg, kvs...)
+		break
+	case LvlDPanic:
+		delegate.DPanic(msg, kvs...)
+		break
+	case LvlPanic:
+		delegate.Panic(msg, kvs...)
+		break
+	case LvlFatal:
+		delegate.Fatal(msg, kvs...)
+		break
+	}
 }
 
-func (l *Logger) skDoFormat(skip int, format string, args ...interface{}) string {
-	msg := format
-	if args != nil && len(args) != 0 {
-		msg = fmt.Sprintf(format, args...)
+const badKey = "!BADKEY"
+
+// keyVals
+// KeyVal{"order_id", 1}, "order_id", 1, []KeyVal{...}, err as Err(err)
+func keyVals(elements ...interface{}) []KeyVal {
+	if len(elements) == 0 {
+		return nil
 	}
-	if l.Config.ReportCaller {
-		msg = l.withCaller(skip, msg)
+	kvs := make([]KeyVal, 0, len(elements))
+	for i := 0; i < len(elements); i++ {
+		switch e := elements[i].(type) {
+		case KeyVal:
+			if lazy, ok := e.Val.(LazyArg); ok {
+				e.Val = lazy()
+			}
+			kvs = append(kvs, e)
+		case []KeyVal:
+			kvs = append(kvs, e...)
+		case error:
+			kvs = append(kvs, Err(e))
+		case string:
+			if i+1 < len(elements) {
+				val := elements[i+1]
+				if lazy, ok := val.(LazyArg); ok {
+					val = lazy()
+				}
+				kvs = append(kvs, KeyVal{Key: e, Val: val})
+				i++
+			} else {
+				kvs = append(kvs, KeyVal{Key: badKey, Val: e})
+			}
+		default:
+			kvs = append(kvs, KeyVal{Key: badKey, Val: e})
+		}
 	}
-	return msg
+	return kvs
 }
 
-func (l *Logger) withCaller(skip int, format string) string {
-	pc, file, line, _ := runtime.Caller(skip)
-	funcName := stringAfterLast(runtime.FuncForPC(pc).Name(), SLASH)
-	packName := l.Config.Name
-	fileName := stringAfterLast(file, SLASH)
-	fileLine := line
-	return fmt.Sprintf("%s(%s/%s:%d)\t%s", funcName, packName, fileName, fileLine, format)
+// joinKeyVals returns a new slice, neither bound nor kvs is modified.
+func joinKeyVals(bound []KeyVal, kvs []KeyVal) []KeyVal {
+	if len(bound) == 0 {
+		return kvs
+	}
+	joined := make([]KeyVal, 0, len(bound)+len(kvs))
+	joined = append(joined, bound...)
+	return append(joined, kvs...)
 }
 
 func stringAfterLast(origin, last string) string {
@@ -188,6 +597,15 @@ func logLevelNum(level string) LevelNum {
 	return levelNum
 }
 
+// isLevelName reports whether level is a level name, logLevelNum falls back to INFO otherwise.
+func isLevelName(level string) bool {
+	switch strings.ToUpper(level) {
+	case "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "DPANIC", "PANIC", "FATAL":
+		return true
+	}
+	return false
+}
+
 func logLevelName(num LevelNum) string {
 	name := "Info"
 	switch num {
@@ -0,0 +1,159 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func disabledLogger(tb testing.TB, impl string) *Logger {
+	f, err := NewLoggerFactory(impl, func(caller string) string { return caller })
+	if err != nil {
+		tb.Fatal(err)
+	}
+	config := &LoggingConfig{RootLevel: "info", ReportCaller: true, Appenders: []AppenderConfig{{Type: "stdout"}}}
+	return f.NewPackageLogger("bench/"+impl, config)
+}
+
+// logDisabled logs once with every flavour of the Debug methods.
+func logDisabled(l *Logger, child *Logger, ctx context.Context) {
+	l.Debug("x %d %s", 42, "s")
+	child.Debugw("msg", "k", 1)
+	l.DebugCtx(ctx, "y")
+	l.DebugFn(func() string { return "z" })
+	l.Debug("lazy %v", LazyArg(func() interface{} { return 1 }))
+}
+
+func TestDisabledLevelsDoNotAllocate(t *testing.T) {
+	for _, impl := range testBackends {
+		l := disabledLogger(t, impl)
+		child := l.With(KeyVal{Key: "k", Val: 1})
+		ctx := context.Background()
+		if allocs := testing.AllocsPerRun(100, func() { logDisabled(l, child, ctx) }); allocs != 0 {
+			t.Errorf("%s: %v allocations per disabled entry", impl, allocs)
+		}
+	}
+}
+
+func TestStructuredFields(t *testing.T) {
+	for _, impl := range testBackends {
+		config, out := testMemoryConfig(t, "json")
+		l := testFactory(t, impl).NewPackageLogger("fields/"+impl, config)
+		l.Infow("order placed", "order_id", 42, KeyVal{Key: "paid", Val: true}, []KeyVal{{Key: "amount", Val: 9.5}}, "dangling")
+		l.Info("order %d placed", 43)
+
+		entries := jsonLines(t, out.lines())
+		if len(entries) != 2 {
+			t.Fatalf("%s: entries %v", impl, entries)
+		}
+		first := entries[0]
+		if jsonMessage(first) != "order placed" || first["order_id"] != float64(42) || first["paid"] != true ||
+			first["amount"] != 9.5 || first[badKey] != "dangling" {
+			t.Errorf("%s: %v", impl, first)
+		}
+		if jsonMessage(entries[1]) != "order 43 placed" || entries[1]["order_id"] != nil {
+			t.Errorf("%s: %v", impl, entries[1])
+		}
+	}
+}
+
+func TestWithBindsFields(t *testing.T) {
+	for _, impl := range testBackends {
+		config, out := testMemoryConfig(t, "json")
+		f := testFactory(t, impl)
+		l := f.NewPackageLogger("with/"+impl, config)
+		child := l.With(KeyVal{Key: "request_id", Val: "r-1"})
+		grandchild := child.With(KeyVal{Key: "user", Val: "u-1"})
+		grandchild.Infow("handled", "status", 200)
+		child.Debug("hidden")
+		l.Info("parent")
+
+		entries := jsonLines(t, out.lines())
+		if len(entries) != 2 {
+			t.Fatalf("%s: entries %v", impl, entries)
+		}
+		if e := entries[0]; e["request_id"] != "r-1" || e["user"] != "u-1" || e["status"] != float64(200) {
+			t.Errorf("%s: %v", impl, e)
+		}
+		if e := entries[1]; e["request_id"] != nil || e["user"] != nil {
+			t.Errorf("%s: the fields of the children leaked to the parent: %v", impl, e)
+		}
+
+		// the children follow the level of the package and are not registered
+		if err := f.SetLevels("with/"+impl, "debug"); err != nil {
+			t.Fatal(err)
+		}
+		grandchild.Debug("shown")
+		if lines := out.lines(); len(lines) != 1 || !strings.Contains(lines[0], "shown") {
+			t.Errorf("%s: lines %q", impl, lines)
+		}
+		if levels := f.GetLevels("with/" + impl); len(levels) != 1 {
+			t.Errorf("%s: levels %v", impl, levels)
+		}
+	}
+}
+
+func BenchmarkDisabled(b *testing.B) {
+	for _, impl := range testBackends {
+		l := disabledLogger(b, impl)
+		child := l.With(KeyVal{Key: "k", Val: 1})
+		ctx := context.Background()
+		b.Run(impl, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				logDisabled(l, child, ctx)
+			}
+		})
+	}
+}
+
+func BenchmarkIsDebugEnabled(b *testing.B) {
+	for _, impl := range testBackends {
+		l := disabledLogger(b, impl)
+		b.Run(impl, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if l.IsDebugEnabled() {
+					b.Fatal("debug enabled")
+				}
+			}
+		})
+	}
+}
+
+func TestTraceLevel(t *testing.T) {
+	for _, impl := range testBackends {
+		config, out := testMemoryConfig(t, "json")
+		config.RootLevel = "trace"
+		l := testFactory(t, impl).NewPackageLogger("trace/"+impl, config)
+		l.Trace("t")
+		l.Debug("d")
+		entries := jsonLines(t, out.lines())
+		if len(entries) != 2 || !strings.EqualFold(fmt.Sprint(entries[0]["level"]), "trace") ||
+			!strings.EqualFold(fmt.Sprint(entries[1]["level"]), "debug") {
+			t.Errorf("%s json: %v", impl, entries)
+		}
+
+		config, out = testMemoryConfig(t, "pattern")
+		config.RootLevel = "trace"
+		config.Pattern = "%level %msg%n"
+		l = testFactory(t, impl).NewPackageLogger("trace/"+impl, config)
+		l.Trace("t")
+		l.Debug("d")
+		if rows := strings.Join(out.lines(), ","); rows != "TRACE t,DEBUG d" {
+			t.Errorf("%s pattern: %s", impl, rows)
+		}
+
+		// a logger at debug drops the trace entries
+		config, out = testMemoryConfig(t, "pattern")
+		config.RootLevel = "debug"
+		config.Pattern = "%level %msg%n"
+		l = testFactory(t, impl).NewPackageLogger("trace/"+impl, config)
+		l.Trace("t")
+		l.Debug("d")
+		if rows := strings.Join(out.lines(), ","); rows != "DEBUG d" {
+			t.Errorf("%s debug: %s", impl, rows)
+		}
+	}
+}
@@ -1,9 +1,8 @@
 package factory
 
 import (
-	"fmt"
 	"github.com/sirupsen/logrus"
-	"reflect"
+	"io"
 	"runtime"
 	"strings"
 )
@@ -11,125 +10,58 @@ import (
 type LogrusLoggerFactory string
 
 func (lf *LogrusLoggerFactory) NewFactory(callerPackageDetector func(caller string) string) *LoggerFactory {
-	internalFactory := LogrusLoggerFactoryImpl
-	factory := &LoggerFactory{
-		callerPackage: callerPackageDetector,
-		delegate:      &internalFactory,
-	}
-	return factory
-}
-
-func (lf *LogrusLoggerFactory) fields(elements ...interface{}) []interface{} {
-	if elements != nil {
-		sz := len(elements)
-		if sz > 0 {
-			fields := make([]interface{}, sz, sz)
-			for i, e := range elements {
-				if reflect.TypeOf(e).Name() == "KeyVal" {
-					kv := e.(KeyVal)
-					field := kv.Key + ":" + fmt.Sprint(kv.Val)
-					fields[i] = field
-				} else {
-					fields[i] = e
-				}
-			}
-			return fields
-		}
-	}
-	return nil
-}
-
-func (lf *LogrusLoggerFactory) setLevel(name string, level logrus.Level) *logrus.Logger {
-	logger := loggers[name]
-	return lf.newLogrusLogger(logger.Config, level)
-}
-
-func (lf *LogrusLoggerFactory) getLevels(prefix string) map[string]string {
-	levels := make(map[string]string, 16)
-	if "ROOT" == strings.ToUpper(prefix) || "" == prefix {
-		for k, logger := range loggers {
-			levels[k] = logLevelName(logger.Config.Level)
-		}
-	} else {
-		for k, logger := range loggers {
-			if strings.HasPrefix(k, prefix) {
-				levels[k] = logLevelName(logger.Config.Level)
-			}
-		}
-	}
-	return levels
-}
-
-func (lf *LogrusLoggerFactory) setLevels(prefix string, level string) {
-	if "ROOT" == strings.ToUpper(prefix) || "" == prefix {
-		for _, logger := range loggers {
-			lf.setLoggerLevel(logger, level)
-		}
-		return
-	}
-	for k, logger := range loggers {
-		if strings.HasPrefix(k, prefix) {
-			lf.setLoggerLevel(logger, level)
-		}
-	}
+	backend := LogrusLoggerFactoryImpl
+	return newLoggerFactory(callerPackageDetector, &backend)
 }
 
-func (lf *LogrusLoggerFactory) setLoggerLevel(logger *Logger, level string) {
-	var logrusLevel logrus.Level
-	var levelNum LevelNum
-	logrusLevel, levelNum = lf.logLevel(level)
-	sink := lf.newLogrusLogger(logger.Config, logrusLevel)
-	delegate := &LogrusLogger{
-		sink:    sink,
-		factory: lf,
+// SetLevel flips the level of the logrus.Logger shared by the logger and its children, the hooks are kept.
+func (lf *LogrusLoggerFactory) SetLevel(delegate LoggerDelegate, loggerConfig *LoggerConfig) LoggerDelegate {
+	logger, ok := delegate.(*LogrusLogger)
+	if !ok {
+		return lf.NewDelegate(loggerConfig)
 	}
-	logger.Config.Level = levelNum
-	logger.delegate = delegate
+	logrusLevel, _ := lf.logLevel(logLevelName(loggerConfig.Level))
+	logger.sink.SetLevel(logrusLevel)
+	return logger
 }
 
-// newLogger
+// NewDelegate
 // []string{"stdout", "logs/application.log"},
-func (lf *LogrusLoggerFactory) newLogger(loggerConfig *LoggerConfig) *Logger {
+func (lf *LogrusLoggerFactory) NewDelegate(loggerConfig *LoggerConfig) LoggerDelegate {
 	logrusLevel, _ := lf.logLevel(logLevelName(loggerConfig.Level))
 	sink := lf.newLogrusLogger(loggerConfig, logrusLevel)
-	delegate := &LogrusLogger{
+	return &LogrusLogger{
+		name:    loggerConfig.Name,
 		sink:    sink,
+		entries: needsEntries(loggerConfig.Outputs),
 		factory: lf,
 	}
-	return &Logger{
-		Config:   loggerConfig,
-		delegate: delegate,
-	}
 }
 
-type logrusHook string
+// logrusCallerKey is the key of the caller in the context of the entries, set when report-caller is set.
+type logrusCallerKey struct{}
+
+// logrusEntryKey is the key of the *Entry in the context of the entries, set when an appender needs the entries.
+type logrusEntryKey struct{}
 
-func (h logrusHook) Levels() []logrus.Level {
+// logrusCallerHook sets the caller found by the Logger on the entries.
+type logrusCallerHook struct{}
+
+func (h logrusCallerHook) Levels() []logrus.Level {
 	return logrus.AllLevels
 }
 
-const hookFireFuncSkip = 10 // function Fire may invoke 10th order.
-const slash = "/"
-
-func (h logrusHook) Fire(entry *logrus.Entry) error {
-	pc, file, line, _ := runtime.Caller(hookFireFuncSkip)
-	lastStashIdx := strings.LastIndex(file, slash)
-	if lastStashIdx >= 0 {
-		file = string(h) + ":" + file[lastStashIdx+1:]
-	} else {
-		file = string(h) + ":" + file
-	}
-	entry.Caller = &runtime.Frame{
-		PC:   pc,
-		File: file,
-		Line: line,
+func (h logrusCallerHook) Fire(entry *logrus.Entry) error {
+	if entry.Context != nil {
+		if caller, ok := entry.Context.Value(logrusCallerKey{}).(*runtime.Frame); ok {
+			entry.Caller = caller
+		}
 	}
 	return nil
 }
 
-func (lf *LogrusLoggerFactory) newHook(name string) logrus.LevelHooks {
-	var allLevelHook = logrusHook(name)
-	var allLevelHooks = []logrus.Hook{allLevelHook}
+func (lf *LogrusLoggerFactory) newHook() logrus.LevelHooks {
+	var allLevelHooks = []logrus.Hook{logrusCallerHook{}}
 	return logrus.LevelHooks{
 		logrus.TraceLevel: allLevelHooks,
 		logrus.DebugLevel: allLevelHooks,
@@ -141,16 +73,75 @@ func (lf *LogrusLoggerFactory) newHook(name string) logrus.LevelHooks {
 	}
 }
 
+// logrusOutputHook formats the entries above the level of an output with its formatter,
+// every output of a logger has its hook.
+type logrusOutputHook struct {
+	levels    []logrus.Level
+	formatter logrus.Formatter
+	out       io.Writer
+}
+
+func newLogrusOutputHook(loggerConfig *LoggerConfig, output LoggerOutput) *logrusOutputHook {
+	levels := make([]logrus.Level, 0, len(logrus.AllLevels))
+	for _, level := range logrus.AllLevels {
+		if logrusLevelNum(level) >= output.Level {
+			levels = append(levels, level)
+		}
+	}
+	return &logrusOutputHook{
+		levels:    levels,
+		formatter: logrusFormatter(loggerConfig.Name, output.Formatter, output.Pattern),
+		out:       output.Writer,
+	}
+}
+
+func (h *logrusOutputHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// logrusCallerReporter makes entry.HasCaller() true for the entries carrying a caller,
+// the logger itself never reports it so that logrus doesn't walk the stack again.
+var logrusCallerReporter = &logrus.Logger{ReportCaller: true, Out: io.Discard}
+
+func (h *logrusOutputHook) Fire(entry *logrus.Entry) error {
+	if entry.Caller != nil {
+		reported := *entry
+		reported.Logger = logrusCallerReporter
+		entry = &reported
+	}
+	p, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	out := h.out
+	if entry.Context != nil {
+		if described, ok := entry.Context.Value(logrusEntryKey{}).(*Entry); ok {
+			out = (&entryBridge{entry: described}).writer(out)
+		}
+	}
+	_, err = out.Write(p)
+	return err
+}
+
+// logrusDiscardFormatter leaves the formatting to the hooks of the outputs.
+type logrusDiscardFormatter struct{}
+
+func (logrusDiscardFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
+
 // newLogrusLogger
 // []string{"stdout", "logs/application.log"},
 func (lf *LogrusLoggerFactory) newLogrusLogger(loggerConfig *LoggerConfig, level logrus.Level) *logrus.Logger {
-	merged := writer(loggerConfig.Name, loggerConfig.Appenders)
+	hooks := lf.newHook()
+	for _, output := range loggerConfig.Outputs {
+		hooks.Add(newLogrusOutputHook(loggerConfig, output))
+	}
 	delegate := &logrus.Logger{
-		Out:       merged,
-		Hooks:     lf.newHook(loggerConfig.Name),
-		Formatter: logrusFormatter(loggerConfig.Formatter),
-		//ReportCaller: true, // set to false will cause entry.HasCaller() return false, wtf!
-		ReportCaller: false, // disable caller
+		Out:          io.Discard,
+		Hooks:        hooks,
+		Formatter:    logrusDiscardFormatter{},
+		ReportCaller: false, // the caller is found by the Logger, see logrusCallerHook
 		Level:        level,
 		// ExitFunc exitFunc, // Function to exit the application, defaults to `os.Exit()`
 	}
@@ -158,7 +149,7 @@ func (lf *LogrusLoggerFactory) newLogrusLogger(loggerConfig *LoggerConfig, level
 }
 
 func (lf *LogrusLoggerFactory) logLevel(level string) (logrus.Level, LevelNum) {
-	var logrusLevel logrus.Level
+	var logrusLevel = logrus.InfoLevel
 	var levelNum = LvlInfo
 	switch strings.ToUpper(level) {
 	case "TRACE":
@@ -2,6 +2,7 @@ package factory
 
 import (
 	"github.com/sirupsen/logrus"
+	"runtime"
 	"strings"
 )
 
@@ -20,27 +21,73 @@ var logrusFormatters = map[string]logrus.Formatter{
 		PadLevelText:              true,
 		QuoteEmptyFields:          true,
 		FieldMap:                  nil,
-		CallerPrettyfier:          nil,
+		CallerPrettyfier:          logrusCallerPrettyfier,
 	},
 	"json": &logrus.JSONFormatter{
 		DisableTimestamp:  false,
 		TimestampFormat:   DTFormatNormal,
 		DisableHTMLEscape: true,
 		FieldMap:          nil,
-		CallerPrettyfier:  nil,
+		CallerPrettyfier:  logrusCallerPrettyfier,
 		PrettyPrint:       false,
-		DataKey:           "msg",
 	},
 }
 
-func logrusFormatter(name string) logrus.Formatter {
-	formatter := strings.ToLower(name)
+func logrusFormatter(name string, formatterName string, pattern string) logrus.Formatter {
+	formatter := strings.ToLower(formatterName)
 	if formatter == "normal" {
 		formatter = "normal"
 	} else if formatter == "json" {
 		formatter = "json"
+	} else if formatter == "pattern" {
+		return &logrusPatternFormatter{
+			name:   name,
+			layout: mustPatternLayout(pattern),
+		}
 	} else {
 		formatter = "normal"
 	}
 	return logrusFormatters[strings.ToLower(formatter)]
 }
+
+// logrusPatternFormatter renders the entries of a logger with a patternLayout.
+type logrusPatternFormatter struct {
+	name   string
+	layout *patternLayout
+}
+
+func (f *logrusPatternFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return f.layout.format(&Entry{
+		Logger:  f.name,
+		Level:   logrusLevelNum(entry.Level),
+		Time:    entry.Time,
+		Message: entry.Message,
+		Fields:  logrusKeyVals(entry.Data),
+		Caller:  entry.Caller,
+	}), nil
+}
+
+// logrusCallerPrettyfier prints the file like the other backends: ip/tcp.go:42.
+func logrusCallerPrettyfier(frame *runtime.Frame) (function string, file string) {
+	return frame.Function, shortCallerPath(frame)
+}
+
+func logrusLevelNum(level logrus.Level) LevelNum {
+	switch level {
+	case logrus.TraceLevel:
+		return LvlTrace
+	case logrus.DebugLevel:
+		return LvlDebug
+	case logrus.InfoLevel:
+		return LvlInfo
+	case logrus.WarnLevel:
+		return LvlWarn
+	case logrus.ErrorLevel:
+		return LvlError
+	case logrus.FatalLevel:
+		return LvlFatal
+	case logrus.PanicLevel:
+		return LvlPanic
+	}
+	return LvlInfo
+}
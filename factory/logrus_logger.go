@@ -1,75 +1,158 @@
 package factory
 
 import (
-	"fmt"
+	"context"
 	"github.com/sirupsen/logrus"
-	"reflect"
+	"runtime"
+	"sort"
+	"time"
 )
 
 type LogrusLogger struct {
+	name    string
 	sink    *logrus.Logger
+	entry   *logrus.Entry // fields bound by With, nil on the package logger
+	entries bool          // an appender needs the entries
 	factory *LogrusLoggerFactory
 }
 
-func (l *LogrusLogger) Trace(msg string) {
-	//l.log(msg, l.sink.Trace)
-	l.sink.Trace(msg)
+func (l *LogrusLogger) Trace(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlTrace, 0, msg, kvs...)
 }
-func (l *LogrusLogger) Debug(msg string) {
-	//l.log(msg, l.sink.Debug)
-	l.sink.Debug(msg)
+func (l *LogrusLogger) Debug(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlDebug, 0, msg, kvs...)
 }
-func (l *LogrusLogger) Info(msg string) {
-	//l.log(msg, l.sink.Info)
-	l.sink.Info(msg)
+func (l *LogrusLogger) Info(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlInfo, 0, msg, kvs...)
 }
-func (l *LogrusLogger) Warn(msg string) {
-	// l.log(msg, l.sink.Warn)
-	l.sink.Warn(msg)
+func (l *LogrusLogger) Warn(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlWarn, 0, msg, kvs...)
 }
-func (l *LogrusLogger) Error(msg string) {
-	//l.log(msg, l.sink.Error)
-	l.sink.Error(msg)
+func (l *LogrusLogger) Error(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlError, 0, msg, kvs...)
 }
-func (l *LogrusLogger) Fatal(msg string) {
-	//l.log(msg, l.sink.Fatal)
-	l.sink.Fatal(msg)
+func (l *LogrusLogger) Fatal(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlFatal, 0, msg, kvs...)
 }
-func (l *LogrusLogger) DPanic(msg string) {
-	//l.log(msg, l.sink.Panic)
-	l.sink.Panic(msg)
+func (l *LogrusLogger) DPanic(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlDPanic, 0, msg, kvs...)
 }
-func (l *LogrusLogger) Panic(msg string) {
-	//l.log(msg, l.sink.Panic)
-	l.sink.Panic(msg)
+func (l *LogrusLogger) Panic(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlPanic, 0, msg, kvs...)
 }
 
-func (l *LogrusLogger) log(msg string, f func(...interface{}), args ...interface{}) {
-	if args == nil || len(args) == 0 {
-		f(msg)
+// LogCaller passes the caller to the hooks through the context of the entry,
+// logrus panics on the panic level.
+func (l *LogrusLogger) LogCaller(level LevelNum, pc uintptr, msg string, kvs ...KeyVal) {
+	l.log(level, logrusLevel(level), callerFrame(pc), msg, kvs)
+	if LvlFatal == level {
+		l.sink.Exit(1)
+	}
+}
+
+func (l *LogrusLogger) log(levelNum LevelNum, level logrus.Level, caller *runtime.Frame, msg string, kvs []KeyVal) {
+	entry := l.entry
+	if len(kvs) != 0 {
+		if entry == nil {
+			entry = l.sink.WithFields(l.convert(kvs))
+		} else {
+			entry = entry.WithFields(l.convert(kvs))
+		}
+	}
+	if caller != nil && l.sink.IsLevelEnabled(level) {
+		if entry == nil {
+			entry = logrus.NewEntry(l.sink)
+		}
+		entry = entry.WithContext(context.WithValue(context.Background(), logrusCallerKey{}, caller))
+	}
+	if l.entries && l.sink.IsLevelEnabled(level) {
+		described := &Entry{
+			Logger:  l.name,
+			Level:   levelNum,
+			Time:    time.Now(),
+			Message: msg,
+			Caller:  caller,
+		}
+		ctx := context.Background()
+		if entry == nil {
+			entry = logrus.NewEntry(l.sink)
+		} else {
+			described.Fields = logrusKeyVals(entry.Data)
+			if entry.Context != nil {
+				ctx = entry.Context
+			}
+		}
+		// the hooks of the outputs hand it to the appenders, see logrusOutputHook
+		entry = entry.WithContext(context.WithValue(ctx, logrusEntryKey{}, described))
+	}
+	l.write(level, msg, entry)
+}
+
+func (l *LogrusLogger) write(level logrus.Level, msg string, entry *logrus.Entry) {
+	if entry == nil {
+		l.sink.Log(level, msg)
 		return
 	}
-	appended := make([]interface{}, len(args)+1)
-	appended[0] = msg
-	for i, arg := range args {
-		appended[i+1] = arg
+	entry.Log(level, msg)
+}
+
+func (l *LogrusLogger) With(kvs []KeyVal) LoggerDelegate {
+	entry := l.entry
+	if entry == nil {
+		entry = logrus.NewEntry(l.sink)
+	}
+	return &LogrusLogger{
+		name:    l.name,
+		sink:    l.sink,
+		entry:   entry.WithFields(l.convert(kvs)),
+		entries: l.entries,
+		factory: l.factory,
 	}
-	f(appended...)
 }
 
-func (l *LogrusLogger) convert(elements ...interface{}) []interface{} {
-	if elements == nil || len(elements) == 0 {
+func (l *LogrusLogger) convert(kvs []KeyVal) logrus.Fields {
+	if len(kvs) == 0 {
 		return nil
 	}
-	args := make([]interface{}, len(elements))
-	for i, e := range elements {
-		if reflect.TypeOf(e).Name() == "KeyVal" {
-			kv := e.(KeyVal)
-			arg := kv.Key + ":" + fmt.Sprint(kv.Val)
-			args[i] = arg
-		} else {
-			args[i] = e
-		}
+	fields := make(logrus.Fields, len(kvs))
+	for _, kv := range kvs {
+		fields[kv.Key] = kv.Val
+	}
+	return fields
+}
+
+func logrusKeyVals(data logrus.Fields) []KeyVal {
+	if len(data) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]KeyVal, len(keys))
+	for i, k := range keys {
+		kvs[i] = KeyVal{Key: k, Val: data[k]}
+	}
+	return kvs
+}
+
+func logrusLevel(level LevelNum) logrus.Level {
+	switch level {
+	case LvlTrace:
+		return logrus.TraceLevel
+	case LvlDebug:
+		return logrus.DebugLevel
+	case LvlInfo:
+		return logrus.InfoLevel
+	case LvlWarn:
+		return logrus.WarnLevel
+	case LvlError:
+		return logrus.ErrorLevel
+	case LvlDPanic, LvlPanic:
+		return logrus.PanicLevel
+	case LvlFatal:
+		return logrus.FatalLevel
 	}
-	return args
+	return logrus.InfoLevel
 }
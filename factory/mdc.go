@@ -0,0 +1,143 @@
+package factory
+
+import (
+	"context"
+	"sync"
+)
+
+// MDC is the mapped diagnostic context of SLF4J.
+// Go has no thread locals, so the MDC travels with a context.Context and
+// its entries are added to every entry logged by the *Ctx methods.
+// MDCPut, MDCRemove and MDCClear copy the MDC of ctx, the MDC of the parent and sibling
+// contexts is unchanged; the methods of MDC change it for every context carrying it.
+type MDC struct {
+	lk     sync.RWMutex
+	keys   []string
+	values map[string]string
+}
+
+type mdcContextKey struct{}
+
+// WithMDC returns a ctx carrying a new MDC, initialised with the entries of the MDC of ctx if any.
+// A nil ctx stands for context.Background().
+func WithMDC(ctx context.Context) context.Context {
+	return withMDCCopy(ctx, func(*MDC) {})
+}
+
+// withMDCCopy returns a ctx carrying a copy of the MDC of ctx changed by edit.
+func withMDCCopy(ctx context.Context, edit func(mdc *MDC)) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	mdc := &MDC{values: make(map[string]string)}
+	if parent := MDCFrom(ctx); parent != nil {
+		parent.lk.RLock()
+		mdc.keys = append(make([]string, 0, len(parent.keys)+1), parent.keys...)
+		for k, v := range parent.values {
+			mdc.values[k] = v
+		}
+		parent.lk.RUnlock()
+	}
+	edit(mdc)
+	return context.WithValue(ctx, mdcContextKey{}, mdc)
+}
+
+// MDCFrom returns the MDC carried by ctx, or nil.
+func MDCFrom(ctx context.Context) *MDC {
+	if ctx == nil {
+		return nil
+	}
+	mdc, _ := ctx.Value(mdcContextKey{}).(*MDC)
+	return mdc
+}
+
+// MDCPut returns a ctx carrying a copy of the MDC of ctx with key put, ctx is unchanged.
+func MDCPut(ctx context.Context, key, val string) context.Context {
+	return withMDCCopy(ctx, func(mdc *MDC) { mdc.Put(key, val) })
+}
+func MDCGet(ctx context.Context, key string) string {
+	return MDCFrom(ctx).Get(key)
+}
+
+// MDCRemove returns a ctx carrying a copy of the MDC of ctx without key, ctx is unchanged.
+func MDCRemove(ctx context.Context, key string) context.Context {
+	return withMDCCopy(ctx, func(mdc *MDC) { mdc.Remove(key) })
+}
+
+// MDCClear returns a ctx carrying an empty MDC, ctx is unchanged.
+func MDCClear(ctx context.Context) context.Context {
+	return withMDCCopy(ctx, func(mdc *MDC) { mdc.Clear() })
+}
+
+func (m *MDC) Put(key, val string) {
+	if m == nil {
+		return
+	}
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	if _, exists := m.values[key]; !exists {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = val
+}
+func (m *MDC) Get(key string) string {
+	if m == nil {
+		return ""
+	}
+	m.lk.RLock()
+	defer m.lk.RUnlock()
+	return m.values[key]
+}
+func (m *MDC) Remove(key string) {
+	if m == nil {
+		return
+	}
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	if _, exists := m.values[key]; !exists {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+func (m *MDC) Clear() {
+	if m == nil {
+		return
+	}
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.keys = nil
+	m.values = make(map[string]string)
+}
+
+// CopyOfContextMap returns a copy of the entries, like MDC.getCopyOfContextMap of SLF4J.
+func (m *MDC) CopyOfContextMap() map[string]string {
+	if m == nil {
+		return nil
+	}
+	m.lk.RLock()
+	defer m.lk.RUnlock()
+	values := make(map[string]string, len(m.values))
+	for k, v := range m.values {
+		values[k] = v
+	}
+	return values
+}
+
+// fields appends the entries in insertion order.
+func (m *MDC) fields(kvs []KeyVal) []KeyVal {
+	if m == nil {
+		return kvs
+	}
+	m.lk.RLock()
+	defer m.lk.RUnlock()
+	for _, k := range m.keys {
+		kvs = append(kvs, KeyVal{Key: k, Val: m.values[k]})
+	}
+	return kvs
+}
@@ -0,0 +1,88 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMDCPutCopiesOnWrite(t *testing.T) {
+	parent := MDCPut(context.Background(), "reqId", "r-1")
+	a := MDCPut(parent, "user", "alice")
+	b := MDCPut(parent, "user", "bob")
+	if MDCGet(parent, "user") != "" || MDCGet(a, "user") != "alice" || MDCGet(b, "user") != "bob" {
+		t.Errorf("parent %v a %v b %v", MDCFrom(parent).CopyOfContextMap(), MDCFrom(a).CopyOfContextMap(), MDCFrom(b).CopyOfContextMap())
+	}
+	if MDCGet(a, "reqId") != "r-1" {
+		t.Error("entry of the parent not inherited")
+	}
+	removed := MDCRemove(a, "reqId")
+	cleared := MDCClear(a)
+	if MDCGet(a, "reqId") != "r-1" || MDCGet(removed, "reqId") != "" || MDCGet(removed, "user") != "alice" {
+		t.Errorf("remove: a %v removed %v", MDCFrom(a).CopyOfContextMap(), MDCFrom(removed).CopyOfContextMap())
+	}
+	if len(MDCFrom(cleared).CopyOfContextMap()) != 0 || len(MDCFrom(a).CopyOfContextMap()) != 2 {
+		t.Error("clear changed the MDC of its parent")
+	}
+}
+
+func TestMDCNilContext(t *testing.T) {
+	ctx := MDCPut(nil, "k", "v")
+	if MDCGet(ctx, "k") != "v" {
+		t.Error("MDCPut(nil) lost the entry")
+	}
+	if MDCGet(nil, "k") != "" || MDCFrom(nil) != nil {
+		t.Error("nil ctx has an MDC")
+	}
+}
+
+func TestMDCKeepsInsertionOrder(t *testing.T) {
+	ctx := context.Background()
+	for _, k := range []string{"c", "a", "b"} {
+		ctx = MDCPut(ctx, k, strings.ToUpper(k))
+	}
+	ctx = MDCPut(ctx, "a", "A2")
+	fields := MDCFrom(ctx).fields(nil)
+	if got := fmt.Sprint(fields); got != "[{c C} {a A2} {b B}]" {
+		t.Errorf("fields %s", got)
+	}
+}
+
+func TestMDCConcurrentPuts(t *testing.T) {
+	parent := MDCPut(context.Background(), "reqId", "r-1")
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := parent
+			for j := 0; j < 100; j++ {
+				ctx = MDCPut(ctx, "worker", fmt.Sprint(i))
+				_ = MDCFrom(parent).fields(nil)
+			}
+			if MDCGet(ctx, "worker") != fmt.Sprint(i) {
+				t.Errorf("worker %d sees %s", i, MDCGet(ctx, "worker"))
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(MDCFrom(parent).CopyOfContextMap()) != 1 {
+		t.Errorf("parent changed: %v", MDCFrom(parent).CopyOfContextMap())
+	}
+}
+
+func TestMDCFieldsAreLogged(t *testing.T) {
+	for _, impl := range testBackends {
+		config, out := testMemoryConfig(t, "json")
+		l := testFactory(t, impl).NewPackageLogger("mdc/"+impl, config)
+		ctx := MDCPut(context.Background(), "reqId", "r-42")
+		l.InfoCtx(ctx, "with mdc")
+		l.Info("without")
+		entries := jsonLines(t, out.lines())
+		if len(entries) != 2 || entries[0]["reqId"] != "r-42" || entries[1]["reqId"] != nil {
+			t.Errorf("%s: %v", impl, entries)
+		}
+	}
+}
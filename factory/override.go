@@ -0,0 +1,164 @@
+package factory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// levelOverride is a level set by SetLevels or SetLevelsFor, the latest one matching a logger wins.
+type levelOverride struct {
+	prefix  string
+	level   LevelNum
+	expires time.Time
+	seq     uint64
+	timer   *time.Timer
+}
+
+// LevelOverride is an active override of SetLevelsFor.
+type LevelOverride struct {
+	Prefix  string
+	Level   string
+	Expires time.Time
+}
+
+// Remaining returns the time left until the override expires.
+func (o LevelOverride) Remaining() time.Duration {
+	if remaining := time.Until(o.Expires); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// SetLevelsFor sets the level of the loggers matching prefix like SetLevels,
+// after ttl they go back to the level of package-levels / root-level.
+// A later SetLevelsFor with the same prefix replaces the override.
+func (f *LoggerFactory) SetLevelsFor(prefix string, level string, ttl time.Duration) error {
+	if !isLevelName(level) {
+		return fmt.Errorf("invalid level %q", level)
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl %s", ttl)
+	}
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	if existing, exists := f.overrides[prefix]; exists {
+		existing.timer.Stop()
+	}
+	if f.overrides == nil {
+		f.overrides = make(map[string]*levelOverride)
+	}
+	f.overrideSeq++
+	override := &levelOverride{
+		prefix:  prefix,
+		level:   logLevelNum(level),
+		expires: time.Now().Add(ttl),
+		seq:     f.overrideSeq,
+	}
+	override.timer = time.AfterFunc(ttl, func() {
+		f.expireOverride(override)
+	})
+	f.overrides[prefix] = override
+	for _, logger := range factoryLoggers(f) {
+		if matchesPrefix(prefix, logger.Config.Name) {
+			f.setLevel(logger, override.level)
+		}
+	}
+	return nil
+}
+
+// GetLevelOverrides returns the active overrides matching prefix, sorted by prefix.
+func (f *LoggerFactory) GetLevelOverrides(prefix string) []LevelOverride {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	result := make([]LevelOverride, 0, len(f.overrides))
+	for _, o := range f.overrides {
+		if matchesPrefix(prefix, o.prefix) {
+			result = append(result, LevelOverride{
+				Prefix:  o.prefix,
+				Level:   logLevelName(o.level),
+				Expires: o.expires,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Prefix < result[j].Prefix })
+	return result
+}
+
+// levelOverrideOf returns the override setting the level of the logger name, nil when none is active.
+func (f *LoggerFactory) levelOverrideOf(name string) *LevelOverride {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	o := f.overrideOf(name)
+	if o == nil {
+		return nil
+	}
+	return &LevelOverride{Prefix: o.prefix, Level: logLevelName(o.level), Expires: o.expires}
+}
+
+func (f *LoggerFactory) expireOverride(override *levelOverride) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	if f.overrides[override.prefix] != override {
+		return // replaced or cancelled
+	}
+	delete(f.overrides, override.prefix)
+	for _, logger := range factoryLoggers(f) {
+		if matchesPrefix(override.prefix, logger.Config.Name) {
+			f.setLevel(logger, f.levelOf(logger.Config.Name, logger.configured))
+		}
+	}
+}
+
+// rememberLevel records a level of SetLevels for the loggers created later,
+// must be called with f.lk held.
+func (f *LoggerFactory) rememberLevel(prefix string, level LevelNum) {
+	levels := f.levels[:0]
+	for _, l := range f.levels {
+		if !matchesPrefix(prefix, l.prefix) {
+			levels = append(levels, l)
+		}
+	}
+	f.overrideSeq++
+	f.levels = append(levels, &levelOverride{prefix: prefix, level: level, seq: f.overrideSeq})
+}
+
+// levelOf returns the level of the logger name, the latest of SetLevels and SetLevelsFor
+// matching it or configured, must be called with f.lk held.
+func (f *LoggerFactory) levelOf(name string, configured LevelNum) LevelNum {
+	level := configured
+	seq := uint64(0)
+	for _, l := range f.levels {
+		if matchesPrefix(l.prefix, name) {
+			level = l.level
+			seq = l.seq
+		}
+	}
+	if o := f.overrideOf(name); o != nil && o.seq > seq {
+		level = o.level
+	}
+	return level
+}
+
+// overrideOf returns the latest override matching the logger name,
+// must be called with f.lk held.
+func (f *LoggerFactory) overrideOf(name string) *levelOverride {
+	var latest *levelOverride
+	for _, o := range f.overrides {
+		if matchesPrefix(o.prefix, name) && (latest == nil || o.seq > latest.seq) {
+			latest = o
+		}
+	}
+	return latest
+}
+
+// cancelOverrides drops the overrides covered by prefix without restoring levels,
+// must be called with f.lk held.
+func (f *LoggerFactory) cancelOverrides(prefix string) {
+	for k, o := range f.overrides {
+		if matchesPrefix(prefix, o.prefix) {
+			o.timer.Stop()
+			delete(f.overrides, k)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package factory
+
+import (
+	"testing"
+	"time"
+)
+
+func testLevelsFactory(t *testing.T, impl string, names ...string) (*LoggerFactory, map[string]*Logger) {
+	t.Helper()
+	f := testFactory(t, impl)
+	config, _ := testMemoryConfig(t, "normal")
+	config.RootLevel = "warn"
+	loggers := make(map[string]*Logger)
+	for _, name := range names {
+		loggers[name] = f.NewPackageLogger(name, config)
+	}
+	return f, loggers
+}
+
+func TestSetLevelsMatchesPackages(t *testing.T) {
+	f, _ := testLevelsFactory(t, "zap", "protocol", "protocol/ip", "protocol/ip/tcp", "protocol/ipv6")
+	if err := f.SetLevels("protocol/ip", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"protocol": "Warn", "protocol/ip": "Debug", "protocol/ip/tcp": "Debug", "protocol/ipv6": "Warn"}
+	for name, level := range f.GetLevels("") {
+		if want[name] != level {
+			t.Errorf("%s level %s, want %s", name, level, want[name])
+		}
+	}
+	if levels := f.GetLevels("protocol/ip"); len(levels) != 2 {
+		t.Errorf("GetLevels(protocol/ip) %v", levels)
+	}
+	if err := f.SetLevels("ROOT", "error"); err != nil {
+		t.Fatal(err)
+	}
+	for name, level := range f.GetLevels("") {
+		if "Error" != level {
+			t.Errorf("%s level %s after ROOT", name, level)
+		}
+	}
+}
+
+func TestSetLevelsAppliesToLaterLoggers(t *testing.T) {
+	f, _ := testLevelsFactory(t, "zap", "protocol")
+	_ = f.SetLevels("protocol/ip", "trace")
+	config, _ := testMemoryConfig(t, "normal")
+	config.RootLevel = "warn"
+	if !f.NewPackageLogger("protocol/ip/icmp", config).IsTraceEnabled() {
+		t.Error("level not applied to a later sub package")
+	}
+	if f.NewPackageLogger("protocol/ipv6", config).IsInfoEnabled() {
+		t.Error("level applied to a sibling package")
+	}
+}
+
+func TestSetLevelsRejectsInvalidLevels(t *testing.T) {
+	f, loggers := testLevelsFactory(t, "zap", "protocol/ip")
+	if err := f.SetLevels("protocol", "debgu"); err == nil {
+		t.Error("SetLevels accepted debgu")
+	}
+	if err := f.SetLevelsFor("protocol", "verbose", time.Minute); err == nil {
+		t.Error("SetLevelsFor accepted verbose")
+	}
+	if err := f.SetLevelsFor("protocol", "debug", 0); err == nil {
+		t.Error("SetLevelsFor accepted a ttl of 0")
+	}
+	if loggers["protocol/ip"].IsInfoEnabled() || len(f.GetLevelOverrides("")) != 0 {
+		t.Error("invalid level applied")
+	}
+}
+
+func TestSetLevelsFor(t *testing.T) {
+	f, loggers := testLevelsFactory(t, "zap", "ov/a", "ov/b", "ovx")
+	if err := f.SetLevelsFor("ov", "debug", 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetLevelsFor("ov/b", "trace", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !loggers["ov/a"].IsDebugEnabled() || !loggers["ov/b"].IsTraceEnabled() || loggers["ovx"].IsInfoEnabled() {
+		t.Fatalf("levels %v", f.GetLevels(""))
+	}
+	overrides := f.GetLevelOverrides("")
+	if len(overrides) != 2 || overrides[0].Prefix != "ov" || overrides[1].Level != "Trace" || overrides[1].Remaining() <= 0 {
+		t.Errorf("overrides %v", overrides)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for loggers["ov/a"].IsInfoEnabled() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if loggers["ov/a"].IsInfoEnabled() || !loggers["ov/b"].IsTraceEnabled() {
+		t.Errorf("levels after expiry %v", f.GetLevels(""))
+	}
+	if overrides := f.GetLevelOverrides(""); len(overrides) != 1 || overrides[0].Prefix != "ov/b" {
+		t.Errorf("overrides after expiry %v", overrides)
+	}
+
+	// SetLevels cancels the overrides it covers
+	_ = f.SetLevels("ov", "error")
+	if loggers["ov/b"].IsWarnEnabled() || len(f.GetLevelOverrides("")) != 0 {
+		t.Errorf("levels %v overrides %v", f.GetLevels(""), f.GetLevelOverrides(""))
+	}
+}
+
+func TestSetLevelsKeepsDelegates(t *testing.T) {
+	for _, impl := range testBackends {
+		f, loggers := testLevelsFactory(t, impl, "flip")
+		l := loggers["flip"]
+		child := l.With(KeyVal{Key: "k", Val: 1})
+		before := l.getDelegate()
+		_ = f.SetLevels("flip", "debug")
+		if !child.IsDebugEnabled() {
+			t.Errorf("%s: child does not follow the level of its parent", impl)
+		}
+		if l.getDelegate() != before {
+			t.Errorf("%s: delegate rebuilt by SetLevels", impl)
+		}
+	}
+}
+
+// a delegate of another backend is rebuilt instead of panicking
+func TestSetLevelOfForeignDelegate(t *testing.T) {
+	_, loggers := testLevelsFactory(t, "zap", "foreign")
+	config := *loggers["foreign"].GetConfig()
+	config.Level = LvlDebug
+	for _, impl := range testBackends {
+		backend, err := newBackend(impl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delegate := backend.SetLevel(&recordingDelegate{}, &config)
+		if _, foreign := delegate.(*recordingDelegate); foreign || delegate == nil {
+			t.Errorf("%s: delegate %T", impl, delegate)
+		}
+	}
+}
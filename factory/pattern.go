@@ -0,0 +1,582 @@
+package factory
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const defaultPattern = "%d{yyyy-MM-dd HH:mm:ss.SSS} %-5level [%logger] %msg%n"
+
+// patternLayout renders entries with a log4j style conversion pattern, e.g.
+// %d{yyyy-MM-dd HH:mm:ss.SSS} %-5level [%logger] %file:%line - %msg %X{request-id}%n
+//
+//	%d{date pattern}, %date        time, java SimpleDateFormat letters or ISO8601
+//	%p, %le, %level                level, TRACE .. FATAL
+//	%c{n}, %lo{n}, %logger{n}      logger name, the last n segments when n is set
+//	%m, %msg, %message             message
+//	%F, %file, %L, %line, %M, %method  caller file, line and function
+//	%X{key}, %mdc{key}             field of the entry, MDC entries included, %X alone prints all the fields
+//	%highlight{pattern}            the pattern colored by level with ANSI escapes
+//	%n, %%                         newline, percent sign
+//
+// A conversion accepts the format modifiers of log4j, %-5level pads to 5 characters
+// aligned left and %.10logger keeps the last 10 characters.
+type patternLayout struct {
+	elements    []patternElement
+	needsCaller bool
+}
+
+type patternElement struct {
+	literal   string
+	convert   func(buf *bytes.Buffer, r *patternRecord)
+	leftAlign bool
+	min       int
+	max       int
+}
+
+type patternRecord struct {
+	entry *Entry
+	frame runtime.Frame
+}
+
+var patternLayouts = make(map[string]*patternLayout)
+var patternLayoutsLk = &sync.Mutex{}
+
+// patternLayoutOf compiles the pattern once, an empty pattern is defaultPattern.
+func patternLayoutOf(pattern string) (*patternLayout, error) {
+	if len(pattern) == 0 {
+		pattern = defaultPattern
+	}
+	patternLayoutsLk.Lock()
+	defer patternLayoutsLk.Unlock()
+	if layout, exists := patternLayouts[pattern]; exists {
+		return layout, nil
+	}
+	layout, err := newPatternLayout(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	patternLayouts[pattern] = layout
+	return layout, nil
+}
+
+// mustPatternLayout is used by the backends, the patterns are validated by writers.
+func mustPatternLayout(pattern string) *patternLayout {
+	layout, err := patternLayoutOf(pattern)
+	if err != nil {
+		layout, _ = patternLayoutOf(defaultPattern)
+	}
+	return layout
+}
+
+func newPatternLayout(pattern string) (*patternLayout, error) {
+	layout := &patternLayout{}
+	literal := strings.Builder{}
+	for i := 0; i < len(pattern); {
+		if pattern[i] != '%' {
+			literal.WriteByte(pattern[i])
+			i++
+			continue
+		}
+		i++
+		if i == len(pattern) {
+			return nil, fmt.Errorf("dangling %% at the end")
+		}
+		if pattern[i] == '%' {
+			literal.WriteByte('%')
+			i++
+			continue
+		}
+		element := patternElement{}
+		if pattern[i] == '-' {
+			element.leftAlign = true
+			i++
+		}
+		element.min, i = patternNumber(pattern, i)
+		if i < len(pattern) && pattern[i] == '.' {
+			element.max, i = patternNumber(pattern, i+1)
+		}
+		start := i
+		for i < len(pattern) && isPatternLetter(pattern[i]) {
+			i++
+		}
+		word := pattern[start:i]
+		option := ""
+		if i < len(pattern) && pattern[i] == '{' {
+			end := patternOptionEnd(pattern, i)
+			if end < 0 {
+				return nil, fmt.Errorf("missing } after %%%s", word)
+			}
+			option = pattern[i+1 : end]
+			i = end + 1
+		}
+		if "n" == word {
+			literal.WriteByte('\n')
+			continue
+		}
+		convert, needsCaller, err := patternConverter(word, option)
+		if err != nil {
+			return nil, err
+		}
+		if literal.Len() > 0 {
+			layout.elements = append(layout.elements, patternElement{literal: literal.String()})
+			literal.Reset()
+		}
+		element.convert = convert
+		layout.elements = append(layout.elements, element)
+		layout.needsCaller = layout.needsCaller || needsCaller
+	}
+	if literal.Len() > 0 {
+		layout.elements = append(layout.elements, patternElement{literal: literal.String()})
+	}
+	return layout, nil
+}
+
+// patternOptionEnd returns the index of the } closing the { at i, options may nest patterns.
+func patternOptionEnd(pattern string, i int) int {
+	depth := 0
+	for ; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+			break
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+			break
+		}
+	}
+	return -1
+}
+
+func patternNumber(pattern string, i int) (int, int) {
+	n := 0
+	for ; i < len(pattern) && pattern[i] >= '0' && pattern[i] <= '9'; i++ {
+		n = n*10 + int(pattern[i]-'0')
+	}
+	return n, i
+}
+
+func isPatternLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func patternConverter(word string, option string) (func(buf *bytes.Buffer, r *patternRecord), bool, error) {
+	switch word {
+	case "d", "date":
+		format, err := newJavaDateFormat(option)
+		if err != nil {
+			return nil, false, err
+		}
+		return func(buf *bytes.Buffer, r *patternRecord) {
+			format.format(buf, r.entry.Time)
+		}, false, nil
+	case "p", "le", "level":
+		return func(buf *bytes.Buffer, r *patternRecord) {
+			buf.WriteString(strings.ToUpper(logLevelName(r.entry.Level)))
+		}, false, nil
+	case "c", "lo", "logger":
+		segments, _ := strconv.Atoi(option)
+		return func(buf *bytes.Buffer, r *patternRecord) {
+			buf.WriteString(lastSegments(r.entry.Logger, segments))
+		}, false, nil
+	case "m", "msg", "message":
+		return func(buf *bytes.Buffer, r *patternRecord) {
+			buf.WriteString(r.entry.Message)
+		}, false, nil
+	case "F", "file":
+		return func(buf *bytes.Buffer, r *patternRecord) {
+			buf.WriteString(stringAfterLast(r.frame.File, SLASH))
+		}, true, nil
+	case "L", "line":
+		return func(buf *bytes.Buffer, r *patternRecord) {
+			buf.WriteString(strconv.Itoa(r.frame.Line))
+		}, true, nil
+	case "M", "method":
+		return func(buf *bytes.Buffer, r *patternRecord) {
+			function := stringAfterLast(r.frame.Function, SLASH)
+			if dot := strings.IndexByte(function, '.'); dot >= 0 {
+				function = function[dot+1:]
+			}
+			buf.WriteString(function)
+		}, true, nil
+	case "highlight":
+		highlighted, err := newPatternLayout(option)
+		if err != nil {
+			return nil, false, err
+		}
+		return func(buf *bytes.Buffer, r *patternRecord) {
+			buf.WriteString(patternLevelColors[r.entry.Level])
+			highlighted.render(buf, r)
+			buf.WriteString(patternColorReset)
+		}, highlighted.needsCaller, nil
+	case "X", "mdc":
+		if len(option) == 0 {
+			return func(buf *bytes.Buffer, r *patternRecord) {
+				writePatternFields(buf, r.entry.Fields)
+			}, false, nil
+		}
+		return func(buf *bytes.Buffer, r *patternRecord) {
+			// the last value wins like in the json output
+			for i := len(r.entry.Fields) - 1; i >= 0; i-- {
+				if r.entry.Fields[i].Key == option {
+					fmt.Fprint(buf, r.entry.Fields[i].Val)
+					return
+				}
+			}
+		}, false, nil
+	}
+	return nil, false, fmt.Errorf("unknown conversion %%%s", word)
+}
+
+// writePatternFields writes the fields as k1=v1, k2=v2 sorted by key, so every backend
+// prints them in the same order.
+func writePatternFields(buf *bytes.Buffer, fields []KeyVal) {
+	if len(fields) == 0 {
+		return
+	}
+	values := make(map[string]interface{}, len(fields))
+	keys := make([]string, 0, len(fields))
+	for _, kv := range fields {
+		if _, exists := values[kv.Key]; !exists {
+			keys = append(keys, kv.Key)
+		}
+		values[kv.Key] = kv.Val
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		fmt.Fprint(buf, values[k])
+	}
+}
+
+func lastSegments(name string, segments int) string {
+	if segments <= 0 {
+		return name
+	}
+	i := len(name)
+	for ; segments > 0 && i > 0; segments-- {
+		i = strings.LastIndex(name[:i], SLASH)
+		if i < 0 {
+			return name
+		}
+	}
+	return name[i+1:]
+}
+
+func (layout *patternLayout) format(entry *Entry) []byte {
+	buf := &bytes.Buffer{}
+	layout.formatTo(buf, entry)
+	return buf.Bytes()
+}
+
+func (layout *patternLayout) formatTo(buf *bytes.Buffer, entry *Entry) {
+	r := &patternRecord{entry: entry}
+	if entry.Caller != nil {
+		r.frame = *entry.Caller
+	} else if layout.needsCaller {
+		r.frame = patternCaller()
+	}
+	layout.render(buf, r)
+}
+
+func (layout *patternLayout) render(buf *bytes.Buffer, r *patternRecord) {
+	var scratch *bytes.Buffer
+	for _, e := range layout.elements {
+		if e.convert == nil {
+			buf.WriteString(e.literal)
+			continue
+		}
+		if e.min == 0 && e.max == 0 {
+			e.convert(buf, r)
+			continue
+		}
+		if scratch == nil {
+			scratch = &bytes.Buffer{}
+		}
+		scratch.Reset()
+		e.convert(scratch, r)
+		s := scratch.String()
+		if e.max > 0 {
+			// truncated from the beginning like log4j
+			for utf8.RuneCountInString(s) > e.max {
+				_, size := utf8.DecodeRuneInString(s)
+				s = s[size:]
+			}
+		}
+		pad := e.min - utf8.RuneCountInString(s)
+		if pad > 0 && !e.leftAlign {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+		buf.WriteString(s)
+		if pad > 0 && e.leftAlign {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+	}
+}
+
+const patternColorReset = "\x1b[0m"
+
+// patternLevelColors are the ANSI colors of %highlight, like the defaults of log4j.
+var patternLevelColors = map[LevelNum]string{
+	LvlTrace:  "\x1b[2;37m",
+	LvlDebug:  "\x1b[36m",
+	LvlInfo:   "\x1b[32m",
+	LvlWarn:   "\x1b[33m",
+	LvlError:  "\x1b[1;31m",
+	LvlDPanic: "\x1b[1;31m",
+	LvlPanic:  "\x1b[1;31m",
+	LvlFatal:  "\x1b[1;31m",
+}
+
+// patternCallerSkipped are the packages between the caller and the layout.
+var patternCallerSkipped = []string{
+	reflect.TypeOf(Logger{}).PkgPath(),
+	"go.uber.org/zap",
+	"github.com/sirupsen/logrus",
+	"github.com/rs/zerolog",
+	"log/slog",
+	"runtime",
+}
+
+// patternCaller finds the first frame outside lf4go and the backends, the depth of the
+// call varies with the backend and the method used.
+func patternCaller() runtime.Frame {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isPatternCallerSkipped(funcPackage(frame.Function)) {
+			return frame
+		}
+		if !more {
+			return runtime.Frame{}
+		}
+	}
+}
+
+func isPatternCallerSkipped(pkg string) bool {
+	for _, skipped := range patternCallerSkipped {
+		if pkg == skipped || strings.HasPrefix(pkg, skipped+SLASH) {
+			return true
+		}
+	}
+	return false
+}
+
+// javaDateFormat formats a time with the letters of java.text.SimpleDateFormat.
+type javaDateFormat []func(buf *bytes.Buffer, t time.Time)
+
+func newJavaDateFormat(pattern string) (javaDateFormat, error) {
+	format := make(javaDateFormat, 0, 16)
+	err := scanJavaDatePattern(pattern, func(text string) {
+		format = append(format, javaDateText(text))
+	}, func(letter byte, count int) error {
+		field, err := javaDateField(letter, count)
+		if err != nil {
+			return err
+		}
+		format = append(format, field)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format, nil
+}
+
+// scanJavaDatePattern splits a date pattern into the quoted or literal texts and the runs of letters.
+func scanJavaDatePattern(pattern string, text func(text string), field func(letter byte, count int) error) error {
+	switch pattern {
+	case "", "DEFAULT":
+		pattern = "yyyy-MM-dd HH:mm:ss.SSS"
+		break
+	case "ISO8601":
+		pattern = "yyyy-MM-dd'T'HH:mm:ss.SSS"
+		break
+	}
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		if c == '\'' {
+			end := strings.IndexByte(pattern[i+1:], '\'')
+			if end < 0 {
+				return fmt.Errorf("unterminated quote in date pattern %q", pattern)
+			}
+			quoted := pattern[i+1 : i+1+end]
+			if len(quoted) == 0 {
+				quoted = "'"
+			}
+			text(quoted)
+			i += end + 2
+			continue
+		}
+		if !isPatternLetter(c) {
+			text(string(c))
+			i++
+			continue
+		}
+		count := 1
+		for i+count < len(pattern) && pattern[i+count] == c {
+			count++
+		}
+		if err := field(c, count); err != nil {
+			return err
+		}
+		i += count
+	}
+	return nil
+}
+
+// javaDateRegexp returns a regular expression matching the times formatted with the date pattern.
+func javaDateRegexp(pattern string) (string, error) {
+	expr := strings.Builder{}
+	err := scanJavaDatePattern(pattern, func(text string) {
+		expr.WriteString(regexp.QuoteMeta(text))
+	}, func(letter byte, count int) error {
+		if _, err := javaDateField(letter, count); err != nil {
+			return err
+		}
+		switch letter {
+		case 'M':
+			if count >= 3 {
+				expr.WriteString("[A-Za-z]+")
+				return nil
+			}
+			break
+		case 'E':
+			expr.WriteString("[A-Za-z]+")
+			return nil
+		case 'a':
+			expr.WriteString("[AP]M")
+			return nil
+		case 'z':
+			expr.WriteString("[A-Za-z0-9+-]+")
+			return nil
+		case 'Z':
+			expr.WriteString("[+-][0-9]{4}")
+			return nil
+		case 'X':
+			expr.WriteString("(?:Z|[+-][0-9]{2}(?::?[0-9]{2})?)")
+			return nil
+		case 'y':
+			if count == 2 {
+				expr.WriteString("[0-9]{2}")
+				return nil
+			}
+			break
+		case 'S':
+			if count > 9 {
+				count = 9
+			}
+			expr.WriteString("[0-9]{" + strconv.Itoa(count) + "}")
+			return nil
+		}
+		expr.WriteString("[0-9]{" + strconv.Itoa(count) + ",}")
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return expr.String(), nil
+}
+
+func javaDateText(text string) func(buf *bytes.Buffer, t time.Time) {
+	return func(buf *bytes.Buffer, t time.Time) {
+		buf.WriteString(text)
+	}
+}
+
+func javaDateNumber(count int, value func(t time.Time) int) func(buf *bytes.Buffer, t time.Time) {
+	return func(buf *bytes.Buffer, t time.Time) {
+		s := strconv.Itoa(value(t))
+		if pad := count - len(s); pad > 0 {
+			buf.WriteString(strings.Repeat("0", pad))
+		}
+		buf.WriteString(s)
+	}
+}
+
+func javaDateField(letter byte, count int) (func(buf *bytes.Buffer, t time.Time), error) {
+	switch letter {
+	case 'y':
+		if count == 2 {
+			return javaDateNumber(2, func(t time.Time) int { return t.Year() % 100 }), nil
+		}
+		return javaDateNumber(count, time.Time.Year), nil
+	case 'M':
+		if count == 3 {
+			return func(buf *bytes.Buffer, t time.Time) { buf.WriteString(t.Month().String()[:3]) }, nil
+		} else if count > 3 {
+			return func(buf *bytes.Buffer, t time.Time) { buf.WriteString(t.Month().String()) }, nil
+		}
+		return javaDateNumber(count, func(t time.Time) int { return int(t.Month()) }), nil
+	case 'd':
+		return javaDateNumber(count, time.Time.Day), nil
+	case 'D':
+		return javaDateNumber(count, time.Time.YearDay), nil
+	case 'E':
+		if count > 3 {
+			return func(buf *bytes.Buffer, t time.Time) { buf.WriteString(t.Weekday().String()) }, nil
+		}
+		return func(buf *bytes.Buffer, t time.Time) { buf.WriteString(t.Weekday().String()[:3]) }, nil
+	case 'a':
+		return func(buf *bytes.Buffer, t time.Time) { buf.WriteString(t.Format("PM")) }, nil
+	case 'H':
+		return javaDateNumber(count, time.Time.Hour), nil
+	case 'h':
+		return javaDateNumber(count, func(t time.Time) int {
+			if h := t.Hour() % 12; h != 0 {
+				return h
+			}
+			return 12
+		}), nil
+	case 'm':
+		return javaDateNumber(count, time.Time.Minute), nil
+	case 's':
+		return javaDateNumber(count, time.Time.Second), nil
+	case 'S':
+		if count > 9 {
+			count = 9
+		}
+		divisor := 1
+		for i := count; i < 9; i++ {
+			divisor *= 10
+		}
+		return javaDateNumber(count, func(t time.Time) int { return t.Nanosecond() / divisor }), nil
+	case 'z':
+		return func(buf *bytes.Buffer, t time.Time) { buf.WriteString(t.Format("MST")) }, nil
+	case 'Z':
+		return func(buf *bytes.Buffer, t time.Time) { buf.WriteString(t.Format("-0700")) }, nil
+	case 'X':
+		layout := "Z07:00"
+		if count == 1 {
+			layout = "Z07"
+		} else if count == 2 {
+			layout = "Z0700"
+		}
+		return func(buf *bytes.Buffer, t time.Time) { buf.WriteString(t.Format(layout)) }, nil
+	}
+	return nil, fmt.Errorf("unsupported date pattern letter %q", letter)
+}
+
+func (format javaDateFormat) format(buf *bytes.Buffer, t time.Time) {
+	for _, field := range format {
+		field(buf, t)
+	}
+}
@@ -0,0 +1,79 @@
+package factory
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPatternLayout(t *testing.T) {
+	entry := &Entry{
+		Logger:  "protocol/ip/tcp",
+		Level:   LvlWarn,
+		Time:    time.Date(2024, 3, 5, 14, 8, 9, 123456789, time.UTC),
+		Message: "retrying",
+		Fields:  []KeyVal{{Key: "request-id", Val: "r-1"}, {Key: "attempt", Val: 2}, {Key: "request-id", Val: "r-2"}},
+		Caller:  &runtime.Frame{File: "/src/protocol/ip/tcp/conn.go", Line: 42, Function: "example.com/protocol/ip/tcp.(*Conn).Dial"},
+	}
+	for pattern, want := range map[string]string{
+		"":                                       "2024-03-05 14:08:09.123 WARN  [protocol/ip/tcp] retrying\n",
+		"%d{ISO8601} %p %m":                      "2024-03-05T14:08:09.123 WARN retrying",
+		"%d{dd MMM yyyy, EEE h:mm a} %%":         "05 Mar 2024, Tue 2:08 PM %",
+		"%d{yy-M-d HH:mm:ss.SSSSSS'Z'}":          "24-3-5 14:08:09.123456Z",
+		"%d{''HH'h'}":                            "'14h",
+		"[%-7level][%7p]":                        "[WARN   ][   WARN]",
+		"%c{1} %lo{2} %logger{5} %.6c":           "tcp ip/tcp protocol/ip/tcp ip/tcp",
+		"%F:%L %M":                               "conn.go:42 (*Conn).Dial",
+		"%X{request-id} %mdc{attempt} %X{none}.": "r-2 2 .",
+		"%X":                                     "attempt=2, request-id=r-2",
+		"%highlight{%-5level} %msg%n":            "\x1b[33mWARN \x1b[0m retrying\n",
+	} {
+		layout, err := newPatternLayout(pattern)
+		if "" == pattern {
+			layout, err = patternLayoutOf(pattern)
+		}
+		if err != nil {
+			t.Errorf("%q: %v", pattern, err)
+			continue
+		}
+		if got := string(layout.format(entry)); got != want {
+			t.Errorf("%q: got %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestPatternLayoutErrors(t *testing.T) {
+	for _, pattern := range []string{"%msg %", "%d{yyyy", "%unknown", "%d{qq}", "%d{'HH}", "%highlight{%nope}"} {
+		if _, err := newPatternLayout(pattern); err == nil {
+			t.Errorf("%q accepted", pattern)
+		}
+	}
+	config, _ := testMemoryConfig(t, "pattern")
+	config.Pattern = "%level %oops"
+	f := testFactory(t, "zap")
+	f.NewPackageLogger("pattern/invalid", testFileConfig(t.TempDir(), "pattern.log"))
+	if err := f.Reload(config); err == nil || !strings.Contains(err.Error(), "%oops") {
+		t.Errorf("reload error %v", err)
+	}
+}
+
+func TestPatternFormatter(t *testing.T) {
+	for _, impl := range testBackends {
+		// without report-caller the layout walks the stack to the first frame outside
+		// this package, the test itself is skipped then
+		for pattern, file := range map[string]string{"%-5p [%c{1}] %msg {%X}%n": "", "%-5p [%c{1}] %file %msg {%X}%n": "pattern_test.go "} {
+			config, out := testMemoryConfig(t, "pattern")
+			config.Pattern = pattern
+			config.ReportCaller = len(file) > 0
+			l := testFactory(t, impl).NewPackageLogger("pattern/"+impl, config)
+			l.With(KeyVal{Key: "b", Val: 1}).Infow("hello", "a", "x")
+			l.Warn("warn %d", 2)
+			want := "INFO  [" + impl + "] " + file + "hello {a=x, b=1}\n" +
+				"WARN  [" + impl + "] " + file + "warn 2 {}"
+			if got := strings.Join(out.lines(), "\n"); got != want {
+				t.Errorf("%s %q:\n%s\nwant\n%s", impl, pattern, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package factory
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reload applies config to every logger of f and to the loggers created afterwards,
+// replacing their levels, appenders, formatters and context keys. Levels changed by
+// SetLevels or SetLevelsFor are reset. Nothing is applied when config is invalid,
+// e.g. an unknown appender type, and the appenders no longer used by any logger are closed.
+// The backend of f is kept, config.Factory is ignored.
+func (f *LoggerFactory) Reload(config *LoggingConfig) error {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	type reloaded struct {
+		logger   *Logger
+		config   *LoggerConfig
+		delegate LoggerDelegate
+	}
+	all := factoryLoggers(f)
+	updates := make([]reloaded, 0, len(all))
+	for _, logger := range all {
+		name := logger.Config.Name
+		loggerConfig, err := newLoggerConfig(f.delegate, name, config)
+		if err != nil {
+			closeUnusedAppenders()
+			return fmt.Errorf("logger %s: %w", name, err)
+		}
+		updates = append(updates, reloaded{
+			logger:   logger,
+			config:   loggerConfig,
+			delegate: f.delegate.NewDelegate(loggerConfig),
+		})
+	}
+	f.config = config
+	f.levels = nil
+	f.cancelOverrides("")
+	for _, u := range updates {
+		u.logger.state.Store(&loggerState{config: u.config, delegate: u.delegate})
+		u.logger.configured = u.config.Level
+	}
+	closeUnusedAppenders()
+	return nil
+}
+
+// wrappingAppender is an appender writing to other appenders, e.g. async.
+type wrappingAppender interface {
+	wrapped() []Appender
+}
+
+// closeUnusedAppenders flushes and closes the appenders no logger writes to any more.
+func closeUnusedAppenders() {
+	used := make(map[Appender]bool)
+	for _, logger := range factoryLoggers(nil) {
+		if merged, ok := logger.GetConfig().Writer.(*mergedWriter); ok {
+			markUsedAppenders(merged.delegates, used)
+		}
+	}
+	appendersLk.Lock()
+	unused := make([]Appender, 0)
+	for key, appender := range appenders {
+		if !used[appender] {
+			delete(appenders, key)
+			unused = append(unused, appender)
+		}
+	}
+	appendersLk.Unlock()
+	for _, appender := range unused {
+		_ = appender.Flush()
+		_ = appender.Close()
+	}
+}
+
+func markUsedAppenders(delegates []Appender, used map[Appender]bool) {
+	for _, d := range delegates {
+		used[d] = true
+		if wrapping, ok := d.(wrappingAppender); ok {
+			markUsedAppenders(wrapping.wrapped(), used)
+		}
+	}
+}
+
+// ConfigLoader reads a LoggingConfig from a file, e.g. with yaml.Unmarshal.
+type ConfigLoader func(path string) (*LoggingConfig, error)
+
+const defaultConfigWatchInterval = 5 * time.Second
+
+// ConfigWatcher polls a config file and reloads a LoggerFactory when its content changes.
+// Polling also follows the symlinks swapped by kubernetes when a ConfigMap is updated.
+type ConfigWatcher struct {
+	factory  *LoggerFactory
+	path     string
+	interval time.Duration
+	load     ConfigLoader
+	checksum []byte
+
+	lk       sync.Mutex
+	lastErr  error
+	onReload func(err error)
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// WatchConfig
+// the file at path is checked every interval, 5s when interval is 0.
+func (f *LoggerFactory) WatchConfig(path string, interval time.Duration, load ConfigLoader) *ConfigWatcher {
+	if interval <= 0 {
+		interval = defaultConfigWatchInterval
+	}
+	w := &ConfigWatcher{
+		factory:  f,
+		path:     path,
+		interval: interval,
+		load:     load,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	// the current content is the config the loggers were created with
+	w.checksum, _ = fileChecksum(path)
+	go w.loop()
+	return w
+}
+
+func (w *ConfigWatcher) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+func (w *ConfigWatcher) check() {
+	checksum, err := fileChecksum(w.path)
+	if err != nil {
+		w.setErr(err)
+		return
+	}
+	if bytes.Equal(checksum, w.checksum) {
+		return
+	}
+	w.checksum = checksum
+	err = w.Reload()
+	w.setErr(err)
+	w.lk.Lock()
+	onReload := w.onReload
+	w.lk.Unlock()
+	if onReload != nil {
+		onReload(err)
+	}
+}
+
+// OnReload sets the function called after every reload of a changed file, with its error,
+// nil once the config is applied.
+func (w *ConfigWatcher) OnReload(onReload func(err error)) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	w.onReload = onReload
+}
+
+// Reload loads the file and reloads the factory now.
+func (w *ConfigWatcher) Reload() error {
+	config, err := w.load(w.path)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", w.path, err)
+	}
+	return w.factory.Reload(config)
+}
+
+func (w *ConfigWatcher) setErr(err error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	w.lastErr = err
+}
+
+// Err returns the error of the last reload, nil once a config is applied.
+func (w *ConfigWatcher) Err() error {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	return w.lastErr
+}
+
+func (w *ConfigWatcher) Stop() {
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	<-w.done
+}
+
+func fileChecksum(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
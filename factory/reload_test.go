@@ -0,0 +1,121 @@
+package factory
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	for _, impl := range testBackends {
+		t.Run(impl, func(t *testing.T) {
+			f := testFactory(t, impl)
+			configA, outA := testMemoryConfig(t, "pattern")
+			configA.Pattern = "A %p %m%n"
+			l := f.NewPackageLogger("reload/"+impl, configA)
+			child := l.With(KeyVal{Key: "k", Val: "v"})
+			l.Debug("a-debug")
+			child.Info("a-info")
+
+			invalid := &LoggingConfig{RootLevel: "info", Appenders: []AppenderConfig{{Type: "unknown"}}}
+			if err := f.Reload(invalid); err == nil {
+				t.Fatal("invalid config applied")
+			}
+			child.Info("a-still")
+
+			configB, outB := testMemoryConfig(t, "pattern")
+			configB.RootLevel = "debug"
+			configB.Pattern = "B %p %m%n"
+			if err := f.Reload(configB); err != nil {
+				t.Fatal(err)
+			}
+			l.Debug("b-debug")
+			child.Info("b-info")
+			f.NewPackageLogger("reload2/"+impl, configA).Debug("b-later")
+
+			if got := strings.Join(outA.lines(), "|"); got != "A INFO a-info|A INFO a-still" {
+				t.Errorf("A: %s", got)
+			}
+			if got := strings.Join(outB.lines(), "|"); got != "B DEBUG b-debug|B INFO b-info|B DEBUG b-later" {
+				t.Errorf("B: %s", got)
+			}
+			if !outA.closed {
+				t.Error("the appender no longer used is not closed")
+			}
+		})
+	}
+}
+
+func TestFileAppenderWriteAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	for _, rotate := range []string{"size", "time"} {
+		appender, err := newFileAppender(map[string]string{"log-file-dir": dir, "log-file-name": rotate + ".log", "rotate-by": rotate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := appender.Write([]byte("before\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := appender.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := appender.Write([]byte("after\n")); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("%s: write after close: %v", rotate, err)
+		}
+		content, _ := os.ReadFile(filepath.Join(dir, rotate+".log"))
+		if string(content) != "before\n" {
+			t.Errorf("%s: content %q", rotate, content)
+		}
+	}
+}
+
+func TestConfigWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"RootLevel":"info"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := testFactory(t, "zap")
+	config, _ := testMemoryConfig(t, "normal")
+	l := f.NewPackageLogger("watched", config)
+	w := f.WatchConfig(path, 10*time.Millisecond, func(path string) (*LoggingConfig, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		loaded := &LoggingConfig{}
+		if err := json.Unmarshal(data, loaded); err != nil {
+			return nil, err
+		}
+		loaded.Appenders = config.Appenders
+		return loaded, nil
+	})
+	defer w.Stop()
+	reloads := make(chan error, 4)
+	w.OnReload(func(err error) { reloads <- err })
+
+	waitReload := func() error {
+		select {
+		case err := <-reloads:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reload")
+		}
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(`{"RootLevel":"debug"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitReload(); err != nil || !l.IsDebugEnabled() || w.Err() != nil {
+		t.Errorf("reload error %v, debug %v", err, l.IsDebugEnabled())
+	}
+	if err := os.WriteFile(path, []byte(`{bad`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitReload(); err == nil || w.Err() == nil || !l.IsDebugEnabled() {
+		t.Errorf("invalid config: error %v, debug %v", err, l.IsDebugEnabled())
+	}
+}
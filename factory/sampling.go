@@ -0,0 +1,196 @@
+package factory
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SamplingConfig
+// the entries of a message template, e.g. the format of Info, are counted per interval:
+// the first Initial are logged, then every Thereafter-th. Rate and Burst limit them with a token bucket.
+// A message passes when it passes both, the entries at or above ExemptLevel always pass.
+type SamplingConfig struct {
+	Interval    string  `yaml:"interval"`     // 1s when empty
+	Initial     int     `yaml:"initial"`      // counting is off when 0
+	Thereafter  int     `yaml:"thereafter"`   // none after Initial when 0
+	Rate        float64 `yaml:"rate"`         // entries per second of a message, no limit when 0
+	Burst       int     `yaml:"burst"`        // Rate rounded up when 0
+	ExemptLevel string  `yaml:"exempt-level"` // ERROR when empty
+}
+
+const (
+	defaultSamplingInterval = time.Second
+	samplerSlots            = 1024 // messages hashed to the same slot share their counter and bucket
+)
+
+// sampler drops the entries of a logger beyond its SamplingConfig and counts them.
+type sampler struct {
+	interval   int64 // time.Duration
+	initial    uint64
+	thereafter uint64
+	rate       float64 // tokens per nanosecond
+	burst      float64
+	exempt     LevelNum
+	counters   []sampleCounter
+	buckets    []sampleBucket
+	suppressed atomic.Uint64
+}
+
+type sampleCounter struct {
+	resetAt atomic.Int64
+	count   atomic.Uint64
+}
+
+type sampleBucket struct {
+	lk     sync.Mutex
+	tokens float64
+	last   int64
+}
+
+// newSampler returns nil when config is nil or neither counts nor limits.
+func newSampler(config *SamplingConfig) (*sampler, error) {
+	if config == nil || (config.Initial <= 0 && config.Rate <= 0) {
+		return nil, nil
+	}
+	interval := defaultSamplingInterval
+	if "" != config.Interval {
+		var err error
+		if interval, err = time.ParseDuration(config.Interval); err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid sampling interval %q", config.Interval)
+		}
+	}
+	if config.Thereafter < 0 || config.Burst < 0 {
+		return nil, fmt.Errorf("invalid sampling thereafter %d or burst %d", config.Thereafter, config.Burst)
+	}
+	exempt := LvlError
+	if "" != config.ExemptLevel {
+		if !isLevelName(config.ExemptLevel) {
+			return nil, fmt.Errorf("invalid sampling exempt-level %q", config.ExemptLevel)
+		}
+		exempt = logLevelNum(config.ExemptLevel)
+	}
+	s := &sampler{
+		interval:   int64(interval),
+		initial:    uint64(config.Initial),
+		thereafter: uint64(config.Thereafter),
+		exempt:     exempt,
+	}
+	if config.Initial > 0 {
+		s.counters = make([]sampleCounter, samplerSlots)
+	}
+	if config.Rate > 0 {
+		s.rate = config.Rate / float64(time.Second)
+		s.burst = float64(config.Burst)
+		if config.Burst == 0 {
+			s.burst = math.Ceil(config.Rate)
+		}
+		s.buckets = make([]sampleBucket, samplerSlots)
+	}
+	return s, nil
+}
+
+// allow reports whether an entry at level with the message template is logged,
+// a nil sampler allows every entry.
+func (s *sampler) allow(level LevelNum, template string) bool {
+	if s == nil || level >= s.exempt {
+		return true
+	}
+	slot := sampleSlot(level, template)
+	now := time.Now().UnixNano()
+	if s.counters != nil && !s.counters[slot].allow(now, s.interval, s.initial, s.thereafter) {
+		s.suppressed.Add(1)
+		return false
+	}
+	if s.buckets != nil && !s.buckets[slot].allow(now, s.rate, s.burst) {
+		s.suppressed.Add(1)
+		return false
+	}
+	return true
+}
+
+// suppressedCount returns the number of entries dropped, 0 for a nil sampler.
+func (s *sampler) suppressedCount() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.suppressed.Load()
+}
+
+// allow counts an entry like zap's sampler, the count restarts once the interval is over.
+func (c *sampleCounter) allow(now int64, interval int64, initial uint64, thereafter uint64) bool {
+	var n uint64
+	resetAt := c.resetAt.Load()
+	if resetAt > now {
+		n = c.count.Add(1)
+	} else if c.resetAt.CompareAndSwap(resetAt, now+interval) {
+		c.count.Store(1)
+		n = 1
+	} else {
+		n = c.count.Add(1)
+	}
+	if n <= initial {
+		return true
+	}
+	return thereafter > 0 && (n-initial)%thereafter == 0
+}
+
+func (b *sampleBucket) allow(now int64, rate float64, burst float64) bool {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if b.last == 0 {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+float64(now-b.last)*rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sampleSlot hashes level and template with fnv-1a.
+func sampleSlot(level LevelNum, template string) uint32 {
+	hash := uint32(2166136261) ^ uint32(uint8(level))
+	hash *= 16777619
+	for i := 0; i < len(template); i++ {
+		hash ^= uint32(template[i])
+		hash *= 16777619
+	}
+	return hash % samplerSlots
+}
+
+// packageSampling
+// the sampling of the longest package-sampling entry being callerPackage or a parent of it, sampling otherwise.
+func packageSampling(callerPackage string, config *LoggingConfig) *SamplingConfig {
+	sampling := config.Sampling
+	matched := -1
+	for pkg, pkgSampling := range config.PackageSampling {
+		if len(pkg) > matched && isParentPackage(pkg, callerPackage) {
+			pkgSampling := pkgSampling
+			sampling = &pkgSampling
+			matched = len(pkg)
+		}
+	}
+	return sampling
+}
+
+// Suppressed returns the number of entries of the logger dropped by sampling since it was configured.
+func (l *Logger) Suppressed() uint64 {
+	return l.GetConfig().sampler.suppressedCount()
+}
+
+// GetSuppressed returns the number of entries dropped by sampling by logger name, like GetLevels.
+func (f *LoggerFactory) GetSuppressed(prefix string) map[string]uint64 {
+	suppressed := make(map[string]uint64, 16)
+	for _, logger := range factoryLoggers(f) {
+		if matchesPrefix(prefix, logger.Config.Name) {
+			suppressed[logger.Config.Name] = logger.Suppressed()
+		}
+	}
+	return suppressed
+}
@@ -0,0 +1,111 @@
+package factory
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplerCounts(t *testing.T) {
+	s, err := newSampler(&SamplingConfig{Initial: 3, Thereafter: 10, Interval: "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := make([]int, 0)
+	for i := 1; i <= 25; i++ {
+		if s.allow(LvlWarn, "retry %d") {
+			allowed = append(allowed, i)
+		}
+		if !s.allow(LvlError, "retry %d") {
+			t.Fatal("exempt level sampled")
+		}
+	}
+	if want := []int{1, 2, 3, 13, 23}; !jsonEqual(allowed, want) {
+		t.Errorf("allowed %v, want %v", allowed, want)
+	}
+	if s.suppressedCount() != 20 {
+		t.Errorf("suppressed %d", s.suppressedCount())
+	}
+}
+
+func TestSampleCounterInterval(t *testing.T) {
+	c := &sampleCounter{}
+	interval := int64(time.Second)
+	for i, want := range []bool{true, false, false} {
+		if got := c.allow(int64(i), interval, 1, 0); got != want {
+			t.Errorf("entry %d allowed %v", i, got)
+		}
+	}
+	if !c.allow(interval, interval, 1, 0) {
+		t.Error("count not restarted after the interval")
+	}
+}
+
+func TestSampleBucket(t *testing.T) {
+	b := &sampleBucket{}
+	rate := 5 / float64(time.Second)
+	now := time.Now().UnixNano()
+	for i, want := range []bool{true, true, false} {
+		if got := b.allow(now, rate, 2); got != want {
+			t.Errorf("entry %d allowed %v", i, got)
+		}
+	}
+	now += int64(200 * time.Millisecond)
+	if !b.allow(now, rate, 2) || b.allow(now, rate, 2) {
+		t.Error("a token per 200ms expected")
+	}
+}
+
+func TestSamplingConfig(t *testing.T) {
+	if s, err := newSampler(&SamplingConfig{Interval: "1s"}); s != nil || err != nil {
+		t.Errorf("sampler %v %v without initial and rate", s, err)
+	}
+	for _, config := range []SamplingConfig{
+		{Initial: 1, Interval: "soon"},
+		{Initial: 1, Interval: "-1s"},
+		{Initial: 1, Thereafter: -1},
+		{Rate: 1, Burst: -1},
+		{Rate: 1, ExemptLevel: "loud"},
+	} {
+		if _, err := newSampler(&config); err == nil {
+			t.Errorf("config %+v accepted", config)
+		}
+	}
+}
+
+func TestLoggerSampling(t *testing.T) {
+	for _, impl := range testBackends {
+		config, out := testMemoryConfig(t, "normal")
+		config.Sampling = &SamplingConfig{Initial: 2, Interval: "1m"}
+		config.PackageSampling = map[string]SamplingConfig{
+			"sampling/" + impl + "/limited": {Rate: 1, Burst: 1},
+			"sampling/" + impl + "/off":     {},
+		}
+		f := testFactory(t, impl)
+		sampled := f.NewPackageLogger("sampling/"+impl, config)
+		limited := f.NewPackageLogger("sampling/"+impl+"/limited/sub", config)
+		off := f.NewPackageLogger("sampling/"+impl+"/off", config)
+		for i := 0; i < 5; i++ {
+			sampled.Warn("retry %d", i) // the template is sampled, not the message
+			sampled.Warnw("retry", "i", i)
+			sampled.Error("failed %d", i)
+			limited.Info("limited %d", i)
+			off.Info("off %d", i)
+		}
+		counts := map[string]int{}
+		for _, row := range out.lines() {
+			for _, msg := range []string{"retry", "failed", "limited", "off"} {
+				if strings.Contains(row, msg) {
+					counts[msg]++
+				}
+			}
+		}
+		if counts["retry"] != 4 || counts["failed"] != 5 || counts["limited"] != 1 || counts["off"] != 5 {
+			t.Errorf("%s: counts %v", impl, counts)
+		}
+		suppressed := f.GetSuppressed("sampling/" + impl)
+		if want := map[string]uint64{"sampling/" + impl: 6, "sampling/" + impl + "/limited/sub": 4, "sampling/" + impl + "/off": 0}; !jsonEqual(suppressed, want) {
+			t.Errorf("%s: suppressed %v", impl, suppressed)
+		}
+	}
+}
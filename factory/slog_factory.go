@@ -0,0 +1,176 @@
+package factory
+
+import (
+	"log/slog"
+	"strings"
+)
+
+type SlogLoggerFactory string
+
+const (
+	slogLevelTrace  = slog.Level(-8)
+	slogLevelDPanic = slog.Level(10)
+	slogLevelPanic  = slog.Level(11)
+	slogLevelFatal  = slog.Level(12)
+)
+
+var slogLevelNames = map[slog.Level]string{
+	slogLevelTrace:  "TRACE",
+	slogLevelDPanic: "DPANIC",
+	slogLevelPanic:  "PANIC",
+	slogLevelFatal:  "FATAL",
+}
+
+func (sf *SlogLoggerFactory) NewFactory(callerPackageDetector func(caller string) string) *LoggerFactory {
+	backend := SlogLoggerFactoryImpl
+	return newLoggerFactory(callerPackageDetector, &backend)
+}
+
+// SetLevel flips the shared slog.LevelVar, the handler and its writers are kept.
+func (sf *SlogLoggerFactory) SetLevel(delegate LoggerDelegate, loggerConfig *LoggerConfig) LoggerDelegate {
+	logger, ok := delegate.(*SlogLogger)
+	if !ok {
+		return sf.NewDelegate(loggerConfig)
+	}
+	slogLevel, _ := sf.logLevel(logLevelName(loggerConfig.Level))
+	logger.level.Set(slogLevel)
+	return logger
+}
+
+// NewDelegate
+// []string{"stdout", "logs/application.log"},
+func (sf *SlogLoggerFactory) NewDelegate(loggerConfig *LoggerConfig) LoggerDelegate {
+	slogLevel, _ := sf.logLevel(logLevelName(loggerConfig.Level))
+	level := new(slog.LevelVar)
+	level.Set(slogLevel)
+	logger := &SlogLogger{
+		name:    loggerConfig.Name,
+		level:   level,
+		sink:    slog.New(sf.newHandlers(loggerConfig, level, nil)),
+		factory: sf,
+	}
+	if needsEntries(loggerConfig.Outputs) {
+		logger.entries = loggerConfig
+	}
+	return logger
+}
+
+// newHandlers returns the handlers of the outputs, those of a logging call when bridge is set.
+func (sf *SlogLoggerFactory) newHandlers(loggerConfig *LoggerConfig, level *slog.LevelVar, bridge *entryBridge) slog.Handler {
+	handlers := make([]slog.Handler, 0, len(loggerConfig.Outputs))
+	for _, output := range loggerConfig.Outputs {
+		handlers = append(handlers, sf.newHandler(loggerConfig, output, level, bridge))
+	}
+	return newSlogFanoutHandler(handlers)
+}
+
+func (sf *SlogLoggerFactory) newHandler(loggerConfig *LoggerConfig, output LoggerOutput, level *slog.LevelVar, bridge *entryBridge) slog.Handler {
+	threshold, _ := sf.logLevel(logLevelName(output.Level))
+	leveler := &slogOutputLevel{level: level, threshold: threshold}
+	options := &slog.HandlerOptions{
+		AddSource:   loggerConfig.ReportCaller,
+		Level:       leveler,
+		ReplaceAttr: sf.replaceAttr,
+	}
+	out := bridge.writer(output.Writer)
+	if "json" == strings.ToLower(output.Formatter) {
+		return slog.NewJSONHandler(out, options)
+	} else if "pattern" == strings.ToLower(output.Formatter) {
+		return newSlogPatternHandler(loggerConfig.Name, output.Pattern, leveler, out)
+	}
+	return slog.NewTextHandler(out, options)
+}
+
+// slogOutputLevel is the level of the logger, raised to the threshold of an output.
+type slogOutputLevel struct {
+	level     *slog.LevelVar
+	threshold slog.Level
+}
+
+func (l *slogOutputLevel) Level() slog.Level {
+	if level := l.level.Level(); level > l.threshold {
+		return level
+	}
+	return l.threshold
+}
+
+// replaceAttr formats the time with DTFormatNormal and names the levels slog does not know.
+func (sf *SlogLoggerFactory) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) != 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		if a.Value.Kind() == slog.KindTime {
+			return slog.String(slog.TimeKey, a.Value.Time().Format(DTFormatNormal))
+		}
+	case slog.LevelKey:
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			if name, exists := slogLevelNames[l]; exists {
+				return slog.String(slog.LevelKey, name)
+			}
+		}
+	}
+	return a
+}
+
+func (sf *SlogLoggerFactory) logLevel(level string) (slog.Level, LevelNum) {
+	var slogLevel = slog.LevelInfo
+	var levelNum = LvlInfo
+	switch strings.ToUpper(level) {
+	case "TRACE":
+		slogLevel = slogLevelTrace
+		levelNum = LvlTrace
+		break
+	case "DEBUG":
+		slogLevel = slog.LevelDebug
+		levelNum = LvlDebug
+		break
+	case "INFO":
+		slogLevel = slog.LevelInfo
+		levelNum = LvlInfo
+		break
+	case "WARN":
+		slogLevel = slog.LevelWarn
+		levelNum = LvlWarn
+		break
+	case "ERROR":
+		slogLevel = slog.LevelError
+		levelNum = LvlError
+		break
+	case "DPANIC":
+		slogLevel = slogLevelDPanic
+		levelNum = LvlDPanic
+		break
+	case "PANIC":
+		slogLevel = slogLevelPanic
+		levelNum = LvlPanic
+		break
+	case "FATAL":
+		slogLevel = slogLevelFatal
+		levelNum = LvlFatal
+		break
+	}
+	return slogLevel, levelNum
+}
+
+// slogLevelNum maps the level of a record logged through slog to the nearest lf4go level.
+func slogLevelNum(level slog.Level) LevelNum {
+	switch {
+	case level < slog.LevelDebug:
+		return LvlTrace
+	case level < slog.LevelInfo:
+		return LvlDebug
+	case level < slog.LevelWarn:
+		return LvlInfo
+	case level < slog.LevelError:
+		return LvlWarn
+	case level < slogLevelDPanic:
+		return LvlError
+	case level < slogLevelPanic:
+		return LvlDPanic
+	case level < slogLevelFatal:
+		return LvlPanic
+	}
+	return LvlFatal
+}
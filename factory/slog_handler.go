@@ -0,0 +1,191 @@
+package factory
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// SlogHandler is a slog.Handler routing records into lf4go loggers, so libraries logging
+// through log/slog are controlled by the same LoggingConfig.
+// The logger of a record is named after the import path of the package that logged it,
+// e.g. "github.com/foo/bar", which is the key to use in package-levels.
+type SlogHandler struct {
+	factory *LoggerFactory
+	config  *LoggingConfig
+	loggers *sync.Map   // package -> *Logger
+	lk      *sync.Mutex // creates the logger of a package once
+	attrs   []KeyVal
+	group   string
+}
+
+// NewSlogHandler
+// slog.SetDefault(slog.New(factory.NewSlogHandler(loggerFactory, &config.Logging)))
+func NewSlogHandler(factory *LoggerFactory, config *LoggingConfig) *SlogHandler {
+	return &SlogHandler{
+		factory: factory,
+		config:  config,
+		loggers: &sync.Map{},
+		lk:      &sync.Mutex{},
+	}
+}
+
+// Enabled checks level against the logger of the package calling slog, the first caller
+// outside of log/slog, so the disabled records are not built.
+// The records of a package whose logger fails are enabled, Handle reports the error.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	logger, err := h.logger(slogCallerPackage(h.config.RootName))
+	if err != nil {
+		return true
+	}
+	return logger.GetConfig().Level <= slogLevelNum(level)
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	callerPackage := h.config.RootName
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		callerPackage = funcPackage(frame.Function)
+	}
+	logger, err := h.logger(callerPackage)
+	if err != nil {
+		return err
+	}
+	levelNum := slogLevelNum(r.Level)
+	config := logger.GetConfig()
+	if config.Level > levelNum || !config.sampler.allow(levelNum, r.Message) {
+		return nil
+	}
+	kvs := make([]KeyVal, 0, len(h.attrs)+r.NumAttrs())
+	kvs = append(kvs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		kvs = appendSlogAttr(kvs, h.group, a)
+		return true
+	})
+	kvs = append(kvs, logger.factory.contextFields(ctx, config.ContextKeys)...)
+	if levelNum > LvlError {
+		levelNum = LvlError // never panics nor exits
+	}
+	var pc uintptr
+	if config.ReportCaller {
+		pc = r.PC
+	}
+	delegate := logger.getDelegate()
+	if native, ok := delegate.(CallerDelegate); ok {
+		native.LogCaller(levelNum, pc, r.Message, kvs...)
+		return nil
+	}
+	if pc != 0 {
+		r.Message = callerPrefix(pc, config.Name) + r.Message
+	}
+	switch levelNum {
+	case LvlTrace:
+		delegate.Trace(r.Message, kvs...)
+	case LvlDebug:
+		delegate.Debug(r.Message, kvs...)
+	case LvlInfo:
+		delegate.Info(r.Message, kvs...)
+	case LvlWarn:
+		delegate.Warn(r.Message, kvs...)
+	default:
+		delegate.Error(r.Message, kvs...)
+	}
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	kvs := make([]KeyVal, 0, len(h.attrs)+len(attrs))
+	kvs = append(kvs, h.attrs...)
+	for _, a := range attrs {
+		kvs = appendSlogAttr(kvs, h.group, a)
+	}
+	return &SlogHandler{
+		factory: h.factory,
+		config:  h.config,
+		loggers: h.loggers,
+		lk:      h.lk,
+		attrs:   kvs,
+		group:   h.group,
+	}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{
+		factory: h.factory,
+		config:  h.config,
+		loggers: h.loggers,
+		lk:      h.lk,
+		attrs:   h.attrs,
+		group:   h.group + name + ".",
+	}
+}
+
+// logger creates the logger of a package once, the error of an invalid config is not kept
+// so a Reload fixing the config is picked up.
+func (h *SlogHandler) logger(callerPackage string) (*Logger, error) {
+	if logger, exists := h.loggers.Load(callerPackage); exists {
+		return logger.(*Logger), nil
+	}
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	if logger, exists := h.loggers.Load(callerPackage); exists {
+		return logger.(*Logger), nil
+	}
+	logger, err := h.factory.NewPackageLoggerE(callerPackage, h.config)
+	if err != nil {
+		return nil, err
+	}
+	h.loggers.Store(callerPackage, logger)
+	return logger, nil
+}
+
+// slogCallerPackage
+// the package of the first caller outside of log/slog, like the PC of its records, def when unknown.
+func slogCallerPackage(def string) string {
+	var pcs [16]uintptr
+	n := runtime.Callers(3, pcs[:]) // the caller of Enabled
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if len(frame.Function) > 0 && !strings.HasPrefix(frame.Function, "log/slog.") {
+			return funcPackage(frame.Function)
+		}
+		if !more {
+			return def
+		}
+	}
+}
+
+// funcPackage
+// github.com/foo/bar.(*T).Method -> github.com/foo/bar
+func funcPackage(function string) string {
+	lastSlash := strings.LastIndex(function, "/")
+	dot := strings.Index(function[lastSlash+1:], ".")
+	if dot < 0 {
+		return function
+	}
+	return function[:lastSlash+1+dot]
+}
+
+func appendSlogAttr(kvs []KeyVal, group string, a slog.Attr) []KeyVal {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		prefix := group
+		if a.Key != "" {
+			prefix = group + a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			kvs = appendSlogAttr(kvs, prefix, ga)
+		}
+		return kvs
+	}
+	return append(kvs, KeyVal{Key: group + a.Key, Val: a.Value.Any()})
+}
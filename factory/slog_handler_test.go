@@ -0,0 +1,101 @@
+package factory
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testPackage = "github.com/jeevan86/lf4go/factory"
+
+func TestSlogHandlerRoutesByPackage(t *testing.T) {
+	config, out := testMemoryConfig(t, "json")
+	config.PackageLevels = map[string]string{testPackage: "debug"}
+	f := testFactory(t, "zap")
+	logger := slog.New(NewSlogHandler(f, config)).With("service", "api").WithGroup("req")
+	logger.Debug("handled", "status", 200, slog.Group("user", "id", 7))
+	logger.Log(context.Background(), slog.LevelError+4, "above error")
+
+	entries := jsonLines(t, out.lines())
+	if len(entries) != 2 {
+		t.Fatalf("entries %v", entries)
+	}
+	first := entries[0]
+	// debug is the level of the package
+	if first["service"] != "api" || first["req.status"] != float64(200) || first["req.user.id"] != float64(7) {
+		t.Errorf("entry %v", first)
+	}
+	if level, _ := entries[1]["level"].(string); !strings.EqualFold(level, "error") {
+		t.Errorf("level above error logged as %v", entries[1]["level"])
+	}
+}
+
+func TestSlogHandlerEnabled(t *testing.T) {
+	config, out := testMemoryConfig(t, "json")
+	f := testFactory(t, "zap")
+	handler := NewSlogHandler(f, config)
+	logger := slog.New(handler)
+	if logger.Enabled(context.Background(), slog.LevelDebug) || !logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("Enabled ignores the level of the package")
+	}
+	built := false
+	logger.Debug("hidden", "lazy", slogLazy(func() { built = true }))
+	if built || len(out.lines()) != 0 {
+		t.Error("disabled record built or logged")
+	}
+	_ = f.SetLevels(testPackage, "debug")
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Enabled does not follow SetLevels")
+	}
+}
+
+// slogLazy is a slog.LogValuer calling resolved when the record is handled.
+type slogLazy func()
+
+func (v slogLazy) LogValue() slog.Value {
+	v()
+	return slog.StringValue("resolved")
+}
+
+func TestSlogHandlerCreatesOneLoggerPerPackage(t *testing.T) {
+	config, _ := testMemoryConfig(t, "json")
+	f := testFactory(t, "zap")
+	logger := slog.New(NewSlogHandler(f, config))
+	start := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			logger.Info("first")
+		}()
+	}
+	close(start)
+	wg.Wait()
+	created := 0
+	for _, l := range factoryLoggers(f) {
+		if l.Config.Name == testPackage {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d loggers created for the package", created)
+	}
+}
+
+// an invalid config fails the records of slog instead of exiting
+func TestSlogHandlerReportsInvalidConfig(t *testing.T) {
+	config := &LoggingConfig{RootLevel: "info", Appenders: []AppenderConfig{{Type: "mongodb"}}}
+	handler := NewSlogHandler(testFactory(t, "zap"), config)
+	if !handler.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("record of an invalid config disabled")
+	}
+	err := handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "lost", 0))
+	if err == nil || !strings.Contains(err.Error(), `unknown appender type "mongodb"`) {
+		t.Errorf("error %v", err)
+	}
+}
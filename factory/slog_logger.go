@@ -0,0 +1,127 @@
+package factory
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+type SlogLogger struct {
+	name    string
+	level   *slog.LevelVar
+	sink    *slog.Logger
+	fields  []KeyVal      // bound by With, kept for the entries
+	entries *LoggerConfig // rebuilds the handlers of each call, nil unless an appender needs the entries
+	factory *SlogLoggerFactory
+}
+
+func (l *SlogLogger) Trace(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlTrace, 0, msg, kvs...)
+}
+func (l *SlogLogger) Debug(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlDebug, 0, msg, kvs...)
+}
+func (l *SlogLogger) Info(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlInfo, 0, msg, kvs...)
+}
+func (l *SlogLogger) Warn(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlWarn, 0, msg, kvs...)
+}
+func (l *SlogLogger) Error(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlError, 0, msg, kvs...)
+}
+func (l *SlogLogger) Fatal(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlFatal, 0, msg, kvs...)
+}
+func (l *SlogLogger) DPanic(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlDPanic, 0, msg, kvs...)
+}
+func (l *SlogLogger) Panic(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlPanic, 0, msg, kvs...)
+}
+
+// LogCaller hands a record with pc to the handler, the source is added when report-caller is set.
+func (l *SlogLogger) LogCaller(level LevelNum, pc uintptr, msg string, kvs ...KeyVal) {
+	l.log(level, slogLevel(level), pc, msg, kvs)
+	switch level {
+	case LvlPanic:
+		panic(msg)
+	case LvlFatal:
+		os.Exit(1)
+	}
+}
+
+func (l *SlogLogger) log(levelNum LevelNum, level slog.Level, pc uintptr, msg string, kvs []KeyVal) {
+	ctx := context.Background()
+	handler := l.sink.Handler()
+	if !handler.Enabled(ctx, level) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, msg, pc)
+	r.AddAttrs(l.convert(kvs)...)
+	if l.entries != nil && level >= l.level.Level() {
+		entry := &Entry{
+			Logger:  l.name,
+			Level:   levelNum,
+			Time:    r.Time,
+			Message: msg,
+			Fields:  joinKeyVals(l.fields, kvs),
+			Caller:  callerFrame(pc),
+		}
+		handler = l.factory.newHandlers(l.entries, l.level, &entryBridge{entry: entry})
+		if len(l.fields) > 0 {
+			handler = handler.WithAttrs(l.convert(l.fields))
+		}
+	}
+	_ = handler.Handle(ctx, r)
+}
+
+func (l *SlogLogger) With(kvs []KeyVal) LoggerDelegate {
+	attrs := l.convert(kvs)
+	args := make([]any, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	return &SlogLogger{
+		name:    l.name,
+		level:   l.level,
+		sink:    l.sink.With(args...),
+		fields:  joinKeyVals(l.fields, kvs),
+		entries: l.entries,
+		factory: l.factory,
+	}
+}
+
+func (l *SlogLogger) convert(kvs []KeyVal) []slog.Attr {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make([]slog.Attr, len(kvs))
+	for i, kv := range kvs {
+		attrs[i] = slog.Any(kv.Key, kv.Val)
+	}
+	return attrs
+}
+
+func slogLevel(level LevelNum) slog.Level {
+	switch level {
+	case LvlTrace:
+		return slogLevelTrace
+	case LvlDebug:
+		return slog.LevelDebug
+	case LvlInfo:
+		return slog.LevelInfo
+	case LvlWarn:
+		return slog.LevelWarn
+	case LvlError:
+		return slog.LevelError
+	case LvlDPanic:
+		return slogLevelDPanic
+	case LvlPanic:
+		return slogLevelPanic
+	case LvlFatal:
+		return slogLevelFatal
+	}
+	return slog.LevelInfo
+}
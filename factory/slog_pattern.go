@@ -0,0 +1,123 @@
+package factory
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// slogPatternHandler renders the records of a logger with a patternLayout.
+type slogPatternHandler struct {
+	name   string
+	layout *patternLayout
+	level  slog.Leveler
+	out    io.Writer
+	lk     *sync.Mutex
+	attrs  []KeyVal
+	group  string
+}
+
+func newSlogPatternHandler(name string, pattern string, level slog.Leveler, out io.Writer) slog.Handler {
+	return &slogPatternHandler{
+		name:   name,
+		layout: mustPatternLayout(pattern),
+		level:  level,
+		out:    out,
+		lk:     &sync.Mutex{},
+	}
+}
+
+func (h *slogPatternHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *slogPatternHandler) Handle(_ context.Context, r slog.Record) error {
+	kvs := make([]KeyVal, 0, len(h.attrs)+r.NumAttrs())
+	kvs = append(kvs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		kvs = appendSlogAttr(kvs, h.group, a)
+		return true
+	})
+	p := h.layout.format(&Entry{
+		Logger:  h.name,
+		Level:   slogLevelNum(r.Level),
+		Time:    r.Time,
+		Message: r.Message,
+		Fields:  kvs,
+		Caller:  callerFrame(r.PC),
+	})
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	_, err := h.out.Write(p)
+	return err
+}
+
+func (h *slogPatternHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = make([]KeyVal, 0, len(h.attrs)+len(attrs))
+	clone.attrs = append(clone.attrs, h.attrs...)
+	for _, a := range attrs {
+		clone.attrs = appendSlogAttr(clone.attrs, h.group, a)
+	}
+	return &clone
+}
+
+func (h *slogPatternHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.group = h.group + name + "."
+	return &clone
+}
+
+// slogFanoutHandler hands the records to the handler of every output.
+type slogFanoutHandler struct {
+	handlers []slog.Handler
+}
+
+func newSlogFanoutHandler(handlers []slog.Handler) slog.Handler {
+	if len(handlers) == 1 {
+		return handlers[0]
+	}
+	return &slogFanoutHandler{handlers: handlers}
+}
+
+func (h *slogFanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *slogFanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	for _, handler := range h.handlers {
+		if !handler.Enabled(ctx, r.Level) {
+			continue
+		}
+		if e := handler.Handle(ctx, r.Clone()); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (h *slogFanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &slogFanoutHandler{handlers: handlers}
+}
+
+func (h *slogFanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &slogFanoutHandler{handlers: handlers}
+}
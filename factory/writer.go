@@ -1,68 +1,382 @@
 package factory
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
-var writers = make(map[string]io.Writer)
-var writersLk = &sync.Mutex{}
+// Appender is a destination of encoded log entries, e.g. a file or stdout.
+type Appender interface {
+	io.Writer
+	Flush() error
+	Close() error
+	Name() string
+}
+
+// Entry describes an encoded log entry to the appenders needing more than its bytes.
+type Entry struct {
+	Logger  string
+	Level   LevelNum
+	Time    time.Time
+	Message string
+	Fields  []KeyVal
+	Caller  *runtime.Frame // set when report-caller is set
+	encode  entryEncoder   // of the output the entry is written to, nil when unknown
+}
+
+// entryEncoder encodes an entry like the backend does for an output, e.g. the summary of a dedup appender.
+type entryEncoder func(entry *Entry) []byte
+
+// EntryWriter is implemented by the LoggerConfig.Writer of the built-in backends.
+type EntryWriter interface {
+	WriteEntry(entry *Entry, p []byte) (int, error)
+}
+
+// EntryAppender is an Appender receiving the entry besides its encoded bytes,
+// e.g. to map the level to a severity. Write is still used by backends unable to
+// describe their entries.
+type EntryAppender interface {
+	Appender
+	EntryWriter
+}
+
+// AppenderFactory builds an appender from the options of an AppenderConfig.
+type AppenderFactory func(options map[string]string) (Appender, error)
+
+var appenderFactories = map[string]AppenderFactory{
+	"file":   newFileAppender,
+	"stdout": newStdoutAppender,
+	"stderr": newStderrAppender,
+	"kafka":  newKafkaAppender,
+	"syslog": newSyslogAppender,
+}
+var appenderFactoriesLk = &sync.RWMutex{}
+
+// RegisterAppender makes an appender type available to the appenders of LoggingConfig,
+// registering an existing type replaces it.
+func RegisterAppender(appenderType string, factory AppenderFactory) {
+	appenderFactoriesLk.Lock()
+	defer appenderFactoriesLk.Unlock()
+	appenderFactories[strings.ToLower(appenderType)] = factory
+}
+
+// appenders are shared by every logger configured with the same type and options.
+var appenders = make(map[string]Appender)
+var appendersLk = &sync.Mutex{}
+
+func appenderKey(appender AppenderConfig) string {
+	keys := make([]string, 0, len(appender.Options))
+	for k := range appender.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	key := strings.ToLower(appender.Type)
+	for _, k := range keys {
+		key += "|" + k + "=" + appender.Options[k]
+	}
+	for _, wrapped := range appender.Appenders {
+		key += "|(" + appenderKey(wrapped) + ")"
+	}
+	return key
+}
+
+// newAppender
+// must be called with appendersLk held.
+func newAppender(appender AppenderConfig) (Appender, error) {
+	key := appenderKey(appender)
+	if existing, exists := appenders[key]; exists {
+		return existing, nil
+	}
+	var created Appender
+	var err error
+	if wrapping, exists := wrappingAppenderFactories[strings.ToLower(appender.Type)]; exists {
+		created, err = newWrappingAppender(appender, wrapping)
+	} else {
+		appenderFactoriesLk.RLock()
+		factory, exists := appenderFactories[strings.ToLower(appender.Type)]
+		appenderFactoriesLk.RUnlock()
+		if !exists {
+			return nil, fmt.Errorf("unknown appender type %q", appender.Type)
+		}
+		created, err = factory(appender.Options)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("appender %q: %w", appender.Type, err)
+	}
+	appenders[key] = created
+	return created, nil
+}
+
+// wrappingAppenderFactories build the appenders writing to the appenders of their AppenderConfig.
+var wrappingAppenderFactories = map[string]func(options map[string]string, delegates []Appender) (Appender, error){
+	"async": newAsyncAppender,
+	"dedup": newDedupAppender,
+}
+
+// newWrappingAppender builds an async or dedup appender around its appenders,
+// must be called with appendersLk held.
+func newWrappingAppender(appender AppenderConfig, wrapping func(options map[string]string, delegates []Appender) (Appender, error)) (Appender, error) {
+	delegates := make([]Appender, 0, len(appender.Appenders))
+	for _, wrapped := range appender.Appenders {
+		delegate, err := newAppender(wrapped)
+		if err != nil {
+			return nil, err
+		}
+		delegates = append(delegates, delegate)
+	}
+	return wrapping(appender.Options, delegates)
+}
 
 type mergedWriter struct {
-	delegates []io.Writer
+	delegates  []Appender
+	needsEntry bool         // any delegate is an EntryAppender
+	encode     entryEncoder // set by bindEntryEncoders when needsEntry
 }
 
+// Write writes p to every delegate, an appender failing does not stop the others.
 func (m *mergedWriter) Write(p []byte) (int, error) {
-	var wrote = 0
 	var err error
 	for _, d := range m.delegates {
-		wrote, err = d.Write(p)
+		if _, e := d.Write(p); e != nil && err == nil {
+			err = e
+		}
 	}
-	return wrote, err
+	return len(p), err
 }
 
-func mergeWriter(w ...io.Writer) io.Writer {
+func (m *mergedWriter) WriteEntry(entry *Entry, p []byte) (int, error) {
+	if m.encode != nil {
+		bound := *entry
+		bound.encode = m.encode
+		entry = &bound
+	}
+	var err error
+	for _, d := range m.delegates {
+		var e error
+		if ea, ok := d.(EntryAppender); ok {
+			_, e = ea.WriteEntry(entry, p)
+		} else {
+			_, e = d.Write(p)
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	return len(p), err
+}
+
+func (m *mergedWriter) Sync() error {
+	var err error
+	for _, d := range m.delegates {
+		if e := d.Flush(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func mergeWriter(w ...Appender) *mergedWriter {
 	merged := mergedWriter{
-		delegates: make([]io.Writer, 0),
+		delegates: make([]Appender, 0, len(w)),
 	}
-	for _, e := range w {
-		merged.delegates = append(merged.delegates, e)
+	merged.delegates = append(merged.delegates, w...)
+	for _, d := range w {
+		if _, ok := d.(EntryAppender); ok {
+			merged.needsEntry = true
+		}
 	}
 	return &merged
 }
 
-func writer(name string, appenders []AppenderConfig) io.Writer {
-	writersLk.Lock()
-	defer writersLk.Unlock()
-	appenderWriters := make([]io.Writer, 0)
-	for _, appender := range appenders {
-		var wr io.Writer
-		if "file" == strings.ToLower(appender.Type) {
-			writerConfig := toFileWriterConfig(appender)
-			fileWriter, exists := writers[writerConfig.LogFilePath]
-			if !exists || fileWriter == nil {
-				fileWriter = newLumberjackWriter(writerConfig)
-				writers[writerConfig.LogFilePath] = fileWriter
-			}
-			wr = fileWriter
-		} else if "stdout" == strings.ToLower(appender.Type) {
-			if writers["stdout"] == nil {
-				stdoutWriter := os.Stdout
-				writers["stdout"] = stdoutWriter
+// entryBridge hands the entry being logged to WriteEntry, for backends which give only the
+// encoded bytes to their io.Writer. A bridge is made per logging call with the writers of the
+// call, so the entries of a logger are encoded concurrently, only the appenders lock their state.
+type entryBridge struct {
+	entry *Entry
+}
+
+// needsEntries reports whether an output needs the entries.
+func needsEntries(outputs []LoggerOutput) bool {
+	for _, output := range outputs {
+		if merged, ok := output.Writer.(*mergedWriter); ok && merged.needsEntry {
+			return true
+		}
+	}
+	return false
+}
+
+// writer returns the io.Writer giving the entry being logged to out, out itself when it
+// does not need the entries. b may be nil.
+func (b *entryBridge) writer(out io.Writer) io.Writer {
+	merged, ok := out.(*mergedWriter)
+	if b == nil || !ok || !merged.needsEntry {
+		return out
+	}
+	return &bridgedWriter{entry: b.entry, out: merged}
+}
+
+type bridgedWriter struct {
+	entry *Entry
+	out   *mergedWriter
+}
+
+func (w *bridgedWriter) Write(p []byte) (int, error) {
+	return w.out.WriteEntry(w.entry, p)
+}
+
+// bindEntryEncoders gives the writers of config needing the entries the encoder of their formatter.
+func bindEntryEncoders(backend Backend, config *LoggerConfig) {
+	bind := func(w io.Writer, formatter string, pattern string) {
+		if merged, ok := w.(*mergedWriter); ok && merged.needsEntry {
+			merged.encode = newEntryEncoder(backend, config.Name, formatter, pattern)
+		}
+	}
+	bind(config.Writer, config.Formatter, config.Pattern)
+	for _, output := range config.Outputs {
+		bind(output.Writer, output.Formatter, output.Pattern)
+	}
+}
+
+// newEntryEncoder logs the entries with a delegate of backend writing to a buffer,
+// the levels above Error are logged at Error.
+func newEntryEncoder(backend Backend, name string, formatter string, pattern string) entryEncoder {
+	return func(entry *Entry) []byte {
+		buf := &bufferAppender{}
+		out := mergeWriter(buf)
+		delegate := backend.NewDelegate(&LoggerConfig{
+			Name:            name,
+			Level:           LvlTrace,
+			Formatter:       formatter,
+			Pattern:         pattern,
+			StacktraceLevel: stacktraceOff,
+			Writer:          out,
+			Outputs:         []LoggerOutput{{Formatter: formatter, Pattern: pattern, Level: LvlTrace, Writer: out}},
+		})
+		switch entry.Level {
+		case LvlTrace:
+			delegate.Trace(entry.Message, entry.Fields...)
+		case LvlDebug:
+			delegate.Debug(entry.Message, entry.Fields...)
+		case LvlInfo:
+			delegate.Info(entry.Message, entry.Fields...)
+		case LvlWarn:
+			delegate.Warn(entry.Message, entry.Fields...)
+		default:
+			delegate.Error(entry.Message, entry.Fields...)
+		}
+		return buf.Bytes()
+	}
+}
+
+// bufferAppender keeps the entries in memory.
+type bufferAppender struct {
+	bytes.Buffer
+}
+
+func (a *bufferAppender) Flush() error {
+	return nil
+}
+
+func (a *bufferAppender) Close() error {
+	return nil
+}
+
+func (a *bufferAppender) Name() string {
+	return "buffer"
+}
+
+// LoggerOutput is a group of appenders sharing the formatter and the level threshold
+// of their AppenderConfig.
+type LoggerOutput struct {
+	Formatter string
+	Pattern   string
+	Level     LevelNum  // entries below are not written to Writer
+	Writer    io.Writer // the appenders of the group merged
+}
+
+// writers returns the appenders merged and grouped in outputs, the appenders without a
+// formatter use the formatter of config.
+func writers(config *LoggingConfig) (io.Writer, []LoggerOutput, error) {
+	appendersLk.Lock()
+	defer appendersLk.Unlock()
+	all := make([]Appender, 0, len(config.Appenders))
+	groups := make([][]Appender, 0, 1)
+	outputs := make([]LoggerOutput, 0, 1)
+	for _, appenderConfig := range config.Appenders {
+		if len(appenderConfig.Level) > 0 && !isLevelName(appenderConfig.Level) {
+			return nil, nil, fmt.Errorf("appender %s: invalid level %q", appenderConfig.Type, appenderConfig.Level)
+		}
+		appender, err := newAppender(appenderConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		all = append(all, appender)
+		output := LoggerOutput{
+			Formatter: config.Formatter,
+			Pattern:   config.Pattern,
+			Level:     LvlTrace,
+		}
+		if len(appenderConfig.Formatter) > 0 {
+			output.Formatter = appenderConfig.Formatter
+			output.Pattern = appenderConfig.Pattern
+		}
+		if len(appenderConfig.Level) > 0 {
+			output.Level = logLevelNum(appenderConfig.Level)
+		}
+		if "pattern" == strings.ToLower(output.Formatter) {
+			if _, err := patternLayoutOf(output.Pattern); err != nil {
+				return nil, nil, fmt.Errorf("appender %q: %w", appenderConfig.Type, err)
 			}
-			wr = writers["stdout"]
-		} else if "stderr" == strings.ToLower(appender.Type) {
-			if writers["stderr"] == nil {
-				stderrWriter := os.Stderr
-				writers["stderr"] = stderrWriter
+		}
+		grouped := false
+		for i, o := range outputs {
+			if o.Formatter == output.Formatter && o.Pattern == output.Pattern && o.Level == output.Level {
+				groups[i] = append(groups[i], appender)
+				grouped = true
+				break
 			}
-			wr = writers["stderr"]
 		}
-		appenderWriters = append(appenderWriters, wr)
+		if !grouped {
+			outputs = append(outputs, output)
+			groups = append(groups, []Appender{appender})
+		}
+	}
+	for i := range outputs {
+		outputs[i].Writer = mergeWriter(groups[i]...)
 	}
-	merged := mergeWriter(appenderWriters...)
-	writers[name] = merged
-	return merged
+	return mergeWriter(all...), outputs, nil
+}
+
+type stdAppender struct {
+	name string
+	out  *os.File
+}
+
+func newStdoutAppender(map[string]string) (Appender, error) {
+	return &stdAppender{name: "stdout", out: os.Stdout}, nil
+}
+func newStderrAppender(map[string]string) (Appender, error) {
+	return &stdAppender{name: "stderr", out: os.Stderr}, nil
+}
+
+func (a *stdAppender) Write(p []byte) (int, error) {
+	return a.out.Write(p)
+}
+func (a *stdAppender) Flush() error {
+	// a terminal or a pipe can not be synced, nothing is buffered anyway.
+	_ = a.out.Sync()
+	return nil
+}
+func (a *stdAppender) Close() error {
+	return nil
+}
+func (a *stdAppender) Name() string {
+	return a.name
 }
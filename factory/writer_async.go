@@ -0,0 +1,232 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var asyncAppenderOptionKeyQueueSize = "queue-size"
+var asyncAppenderOptionKeyOverflow = "overflow"
+var asyncAppenderOptionKeyOverflowLevel = "overflow-level"
+
+const defaultAsyncQueueSize = 8192
+const asyncFlushTimeout = 5 * time.Second
+
+type asyncOverflow string
+
+const (
+	asyncOverflowBlock          asyncOverflow = "block"
+	asyncOverflowDropNewest     asyncOverflow = "drop-newest"
+	asyncOverflowDropOldest     asyncOverflow = "drop-oldest"
+	asyncOverflowDropBelowLevel asyncOverflow = "drop-below-level" // drops the entries below overflow-level, blocks the others
+)
+
+type asyncRecord struct {
+	entry *Entry // nil when written by Write
+	p     []byte
+}
+
+// asyncAppender queues the entries in a ring buffer drained by a background goroutine
+// into the wrapped appenders, so a slow appender does not stall the logging goroutines.
+// The wrapped appenders are shared with the other loggers, they are not closed by Close.
+type asyncAppender struct {
+	name          string
+	out           *mergedWriter
+	overflow      asyncOverflow
+	overflowLevel LevelNum
+
+	lk      sync.Mutex
+	cond    *sync.Cond
+	ring    []asyncRecord
+	head    int
+	size    int
+	writing bool
+	dropped uint64
+	closed  bool
+	done    chan struct{}
+}
+
+func newAsyncAppender(options map[string]string, delegates []Appender) (Appender, error) {
+	if len(delegates) == 0 {
+		return nil, errors.New("appenders is required")
+	}
+	queueSize, err := intOption(options, asyncAppenderOptionKeyQueueSize, defaultAsyncQueueSize)
+	if err != nil {
+		return nil, err
+	}
+	overflow := asyncOverflow(strings.ToLower(strings.TrimSpace(options[asyncAppenderOptionKeyOverflow])))
+	switch overflow {
+	case "":
+		overflow = asyncOverflowBlock
+		break
+	case asyncOverflowBlock, asyncOverflowDropNewest, asyncOverflowDropOldest, asyncOverflowDropBelowLevel:
+		break
+	default:
+		return nil, fmt.Errorf("invalid overflow %q", overflow)
+	}
+	overflowLevel := LvlWarn
+	if level := strings.TrimSpace(options[asyncAppenderOptionKeyOverflowLevel]); len(level) > 0 {
+		if !isLevelName(level) {
+			return nil, fmt.Errorf("invalid overflow-level %q", level)
+		}
+		overflowLevel = logLevelNum(level)
+	}
+	names := make([]string, 0, len(delegates))
+	for _, d := range delegates {
+		names = append(names, d.Name())
+	}
+	a := &asyncAppender{
+		name:          "async:" + strings.Join(names, ","),
+		out:           mergeWriter(delegates...),
+		overflow:      overflow,
+		overflowLevel: overflowLevel,
+		ring:          make([]asyncRecord, queueSize),
+		done:          make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.lk)
+	go a.loop()
+	return a, nil
+}
+
+func (a *asyncAppender) Write(p []byte) (int, error) {
+	return a.WriteEntry(nil, p)
+}
+
+// WriteEntry queues a copy of the entry, PANIC and FATAL entries are delivered before it
+// returns since the process is about to stop.
+func (a *asyncAppender) WriteEntry(entry *Entry, p []byte) (int, error) {
+	record := asyncRecord{p: make([]byte, len(p))}
+	copy(record.p, p)
+	if entry != nil {
+		copied := *entry
+		record.entry = &copied
+	}
+	if err := a.enqueue(record); err != nil {
+		return 0, err
+	}
+	if entry != nil && entry.Level >= LvlPanic {
+		_ = a.Flush()
+	}
+	return len(p), nil
+}
+
+func (a *asyncAppender) enqueue(record asyncRecord) error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	for !a.closed && a.size == len(a.ring) {
+		switch a.overflow {
+		case asyncOverflowDropNewest:
+			a.dropped++
+			return nil
+		case asyncOverflowDropOldest:
+			a.ring[a.head] = asyncRecord{}
+			a.head = (a.head + 1) % len(a.ring)
+			a.size--
+			a.dropped++
+			break
+		case asyncOverflowDropBelowLevel:
+			// entries written without their level are kept
+			if record.entry != nil && record.entry.Level < a.overflowLevel {
+				a.dropped++
+				return nil
+			}
+			a.cond.Wait()
+			break
+		default:
+			a.cond.Wait()
+			break
+		}
+	}
+	if a.closed {
+		return errors.New("async appender closed")
+	}
+	a.ring[(a.head+a.size)%len(a.ring)] = record
+	a.size++
+	a.cond.Broadcast()
+	return nil
+}
+
+func (a *asyncAppender) loop() {
+	defer close(a.done)
+	for {
+		a.lk.Lock()
+		for a.size == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if a.size == 0 && a.closed {
+			a.lk.Unlock()
+			return
+		}
+		record := a.ring[a.head]
+		a.ring[a.head] = asyncRecord{}
+		a.head = (a.head + 1) % len(a.ring)
+		a.size--
+		a.writing = true
+		a.cond.Broadcast()
+		a.lk.Unlock()
+
+		if record.entry != nil {
+			_, _ = a.out.WriteEntry(record.entry, record.p)
+		} else {
+			_, _ = a.out.Write(record.p)
+		}
+
+		a.lk.Lock()
+		a.writing = false
+		a.cond.Broadcast()
+		a.lk.Unlock()
+	}
+}
+
+// Flush waits until the queued entries are written and flushes the wrapped appenders,
+// or fails after asyncFlushTimeout.
+func (a *asyncAppender) Flush() error {
+	deadline := time.Now().Add(asyncFlushTimeout)
+	for {
+		a.lk.Lock()
+		pending := a.size
+		if a.writing {
+			pending++
+		}
+		a.lk.Unlock()
+		if pending == 0 {
+			return a.out.Sync()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("async appender: %d entries not written", pending)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Close writes the queued entries and stops the background goroutine.
+func (a *asyncAppender) Close() error {
+	a.lk.Lock()
+	if a.closed {
+		a.lk.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.cond.Broadcast()
+	a.lk.Unlock()
+	<-a.done
+	return a.out.Sync()
+}
+
+func (a *asyncAppender) Name() string {
+	return a.name
+}
+
+// Dropped returns the number of entries dropped by the overflow policy.
+func (a *asyncAppender) Dropped() uint64 {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.dropped
+}
+
+func (a *asyncAppender) wrapped() []Appender {
+	return a.out.delegates
+}
@@ -0,0 +1,161 @@
+package factory
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedAppender records the writes, holding them until the gate is opened.
+type gatedAppender struct {
+	gate    chan struct{}
+	lk      sync.Mutex
+	written []string
+}
+
+func newGatedAppender() *gatedAppender {
+	return &gatedAppender{gate: make(chan struct{})}
+}
+
+func (a *gatedAppender) Write(p []byte) (int, error) {
+	<-a.gate
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	a.written = append(a.written, string(p))
+	return len(p), nil
+}
+
+func (a *gatedAppender) Flush() error {
+	return nil
+}
+
+func (a *gatedAppender) Close() error {
+	return nil
+}
+
+func (a *gatedAppender) Name() string {
+	return "gated"
+}
+
+func (a *gatedAppender) writes() string {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return strings.Join(a.written, ",")
+}
+
+func testAsyncAppender(t *testing.T, options map[string]string, out Appender) *asyncAppender {
+	t.Helper()
+	a, err := newAsyncAppender(options, []Appender{out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.(*asyncAppender)
+}
+
+// fillAsync writes 1 taken by the background goroutine, then 2 and 3 filling a queue of 2.
+func fillAsync(t *testing.T, a *asyncAppender) {
+	t.Helper()
+	_, _ = a.WriteEntry(&Entry{Level: LvlInfo}, []byte("1"))
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		a.lk.Lock()
+		writing := a.writing
+		a.lk.Unlock()
+		if writing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the entry was not taken")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	_, _ = a.WriteEntry(&Entry{Level: LvlInfo}, []byte("2"))
+	_, _ = a.WriteEntry(&Entry{Level: LvlInfo}, []byte("3"))
+}
+
+func TestAsyncOverflow(t *testing.T) {
+	for overflow, want := range map[string]string{
+		"drop-newest":      "1,2,3",
+		"drop-oldest":      "1,3,4",
+		"drop-below-level": "1,2,3",
+	} {
+		out := newGatedAppender()
+		a := testAsyncAppender(t, map[string]string{"queue-size": "2", "overflow": overflow}, out)
+		fillAsync(t, a)
+		_, _ = a.WriteEntry(&Entry{Level: LvlInfo}, []byte("4"))
+		close(out.gate)
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if out.writes() != want || a.Dropped() != 1 {
+			t.Errorf("%s: written %s dropped %d, want %s", overflow, out.writes(), a.Dropped(), want)
+		}
+	}
+}
+
+func TestAsyncOverflowBlocks(t *testing.T) {
+	for _, options := range []map[string]string{
+		{"queue-size": "2"},
+		{"queue-size": "2", "overflow": "drop-below-level", "overflow-level": "error"},
+	} {
+		out := newGatedAppender()
+		a := testAsyncAppender(t, options, out)
+		fillAsync(t, a)
+		written := make(chan struct{})
+		go func() {
+			_, _ = a.WriteEntry(&Entry{Level: LvlError}, []byte("4"))
+			close(written)
+		}()
+		select {
+		case <-written:
+			t.Errorf("%v: the write did not wait for the queue", options)
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(out.gate)
+		<-written
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if out.writes() != "1,2,3,4" || a.Dropped() != 0 {
+			t.Errorf("%v: written %s dropped %d", options, out.writes(), a.Dropped())
+		}
+		_ = a.Close()
+	}
+}
+
+func TestAsyncDeliversPanicsAndCloses(t *testing.T) {
+	out := newGatedAppender()
+	close(out.gate)
+	a := testAsyncAppender(t, map[string]string{}, out)
+	_, _ = a.WriteEntry(&Entry{Level: LvlInfo}, []byte("info"))
+	_, _ = a.WriteEntry(&Entry{Level: LvlPanic}, []byte("panic"))
+	if out.writes() != "info,panic" {
+		t.Errorf("written %s before the panic", out.writes())
+	}
+	_, _ = a.Write([]byte("last"))
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.writes() != "info,panic,last" {
+		t.Errorf("written %s after Close", out.writes())
+	}
+	if _, err := a.Write([]byte("late")); err == nil {
+		t.Error("write after Close succeeded")
+	}
+}
+
+func TestAsyncOptions(t *testing.T) {
+	for _, options := range []map[string]string{
+		{"queue-size": "x"},
+		{"queue-size": "0"},
+		{"overflow": "drop-all"},
+		{"overflow": "drop-below-level", "overflow-level": "loud"},
+	} {
+		if _, err := newAsyncAppender(options, []Appender{newGatedAppender()}); err == nil {
+			t.Errorf("options %v accepted", options)
+		}
+	}
+	if _, err := newAsyncAppender(map[string]string{}, nil); err == nil {
+		t.Error("no appenders accepted")
+	}
+}
@@ -0,0 +1,231 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var dedupAppenderOptionKeyWindow = "window"
+
+const defaultDedupWindow = 10 * time.Second
+
+// dedupAppender collapses the consecutive entries with the same logger, level and message
+// written within window of the first one, "previous message repeated N times" is written
+// when another entry comes, the window closes, or on Flush and Close. The summary is encoded
+// by the backend with the formatter of the output of the run, by the goroutine of the appender
+// so that the logging goroutines never pay for it; the entries coming meanwhile are queued
+// behind the summary to keep their order. The fields are not compared, the fields of the
+// repeats are lost.
+// The wrapped appenders are shared with the other loggers, they are not closed by Close.
+type dedupAppender struct {
+	name   string
+	out    *mergedWriter
+	window time.Duration
+
+	lk       sync.Mutex
+	cond     *sync.Cond
+	last     *Entry // the first entry of the run, nil when there is none
+	started  time.Time
+	repeated int
+	run      uint64 // incremented by every run, ends the timers of the previous ones
+	pending  []dedupWrite
+	writing  bool // loop is writing a pending entry
+	closed   bool
+	done     chan struct{}
+}
+
+// dedupWrite is a summary to encode and write, or an entry queued behind one.
+type dedupWrite struct {
+	entry    *Entry // nil for the entries written by Write
+	p        []byte
+	last     *Entry // the first entry of the run of a summary
+	repeated int
+}
+
+func newDedupAppender(options map[string]string, delegates []Appender) (Appender, error) {
+	if len(delegates) == 0 {
+		return nil, errors.New("appenders is required")
+	}
+	window, err := durationOption(options, dedupAppenderOptionKeyWindow, defaultDedupWindow)
+	if err != nil {
+		return nil, err
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid window %s", window)
+	}
+	names := make([]string, 0, len(delegates))
+	for _, d := range delegates {
+		names = append(names, d.Name())
+	}
+	a := &dedupAppender{
+		name:   "dedup:" + strings.Join(names, ","),
+		out:    mergeWriter(delegates...),
+		window: window,
+		done:   make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.lk)
+	go a.loop()
+	return a, nil
+}
+
+// Write is used by the backends unable to describe their entries, p ends the run and is written as is.
+func (a *dedupAppender) Write(p []byte) (int, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	a.endRun()
+	if a.queued() {
+		a.enqueue(dedupWrite{p: append([]byte(nil), p...)})
+		return len(p), nil
+	}
+	return a.out.Write(p)
+}
+
+func (a *dedupAppender) WriteEntry(entry *Entry, p []byte) (int, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if a.last != nil && a.repeats(entry) && time.Since(a.started) < a.window {
+		if a.repeated == 0 {
+			a.closeWindowAfter(a.window - time.Since(a.started))
+		}
+		a.repeated++
+		return len(p), nil
+	}
+	a.endRun()
+	if !a.closed {
+		copied := *entry
+		a.last = &copied
+		a.started = time.Now()
+		a.run++
+	}
+	if a.queued() {
+		copied := *entry
+		a.enqueue(dedupWrite{entry: &copied, p: append([]byte(nil), p...)})
+		return len(p), nil
+	}
+	return a.out.WriteEntry(entry, p)
+}
+
+func (a *dedupAppender) repeats(entry *Entry) bool {
+	return a.last.Logger == entry.Logger && a.last.Level == entry.Level && a.last.Message == entry.Message
+}
+
+// closeWindowAfter ends the run when the window closes unless another entry ended it before,
+// must be called with lk held.
+func (a *dedupAppender) closeWindowAfter(d time.Duration) {
+	run := a.run
+	time.AfterFunc(d, func() {
+		a.lk.Lock()
+		defer a.lk.Unlock()
+		if a.run == run {
+			a.endRun()
+		}
+	})
+}
+
+// endRun queues the summary of the run if it has repeats, the next entry starts a new run,
+// must be called with lk held.
+func (a *dedupAppender) endRun() {
+	last, repeated := a.last, a.repeated
+	a.last = nil
+	a.repeated = 0
+	a.run++
+	if last == nil || repeated == 0 {
+		return
+	}
+	a.enqueue(dedupWrite{last: last, repeated: repeated})
+}
+
+// queued reports whether loop has entries to write, the later ones are queued behind them,
+// must be called with lk held.
+func (a *dedupAppender) queued() bool {
+	return len(a.pending) > 0 || a.writing
+}
+
+// enqueue must be called with lk held.
+func (a *dedupAppender) enqueue(w dedupWrite) {
+	a.pending = append(a.pending, w)
+	a.cond.Broadcast()
+}
+
+// loop writes the summaries and the entries queued behind them, until Close.
+func (a *dedupAppender) loop() {
+	defer close(a.done)
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	for {
+		for len(a.pending) == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if len(a.pending) == 0 {
+			return
+		}
+		w := a.pending[0]
+		a.pending = a.pending[1:]
+		a.writing = true
+		a.lk.Unlock()
+		if w.last != nil {
+			summary := &Entry{
+				Logger:  w.last.Logger,
+				Level:   w.last.Level,
+				Time:    time.Now(),
+				Message: fmt.Sprintf("previous message repeated %d times", w.repeated),
+				Fields:  []KeyVal{{Key: "repeated", Val: w.repeated}},
+			}
+			_, _ = a.out.WriteEntry(summary, a.encode(w.last, summary))
+		} else if w.entry != nil {
+			_, _ = a.out.WriteEntry(w.entry, w.p)
+		} else {
+			_, _ = a.out.Write(w.p)
+		}
+		a.lk.Lock()
+		a.writing = false
+		a.cond.Broadcast()
+	}
+}
+
+// encode formats the summary like the entries of the run, the message alone when their encoder is unknown.
+func (a *dedupAppender) encode(last *Entry, summary *Entry) []byte {
+	if last.encode != nil {
+		return last.encode(summary)
+	}
+	return []byte(summary.Message + "\n")
+}
+
+// drain waits until loop wrote the queued entries, must be called with lk held.
+func (a *dedupAppender) drain() {
+	for a.queued() {
+		a.cond.Wait()
+	}
+}
+
+// Flush writes the summary of the current run and flushes the wrapped appenders.
+func (a *dedupAppender) Flush() error {
+	a.lk.Lock()
+	a.endRun()
+	a.drain()
+	a.lk.Unlock()
+	return a.out.Sync()
+}
+
+// Close writes the summary of the current run, the entries written afterwards are not collapsed.
+func (a *dedupAppender) Close() error {
+	a.lk.Lock()
+	a.endRun()
+	a.drain()
+	a.closed = true
+	a.cond.Broadcast()
+	a.lk.Unlock()
+	<-a.done
+	return a.out.Sync()
+}
+
+func (a *dedupAppender) Name() string {
+	return a.name
+}
+
+func (a *dedupAppender) wrapped() []Appender {
+	return a.out.delegates
+}
@@ -0,0 +1,159 @@
+package factory
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var dedupTime = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}\.\d{3}`)
+
+// testDedupLogger returns a logger writing through a dedup appender to a memory appender.
+func testDedupLogger(t *testing.T, impl string, formatter string, window string) (*Logger, *dedupAppender, *memoryAppender) {
+	t.Helper()
+	config, out := testMemoryConfig(t, formatter)
+	config.Pattern = "%d %p %c %m%n"
+	config.Appenders = []AppenderConfig{{Type: "dedup", Options: map[string]string{"window": window}, Appenders: config.Appenders}}
+	logger := testFactory(t, impl).NewPackageLogger("dedup/"+formatter, config)
+	appendersLk.Lock()
+	defer appendersLk.Unlock()
+	for _, a := range appenders {
+		if dedup, ok := a.(*dedupAppender); ok && dedup.wrapped()[0] == Appender(out) {
+			return logger, dedup, out
+		}
+	}
+	t.Fatal("no dedup appender")
+	return nil, nil, nil
+}
+
+// assertSummary checks summary is encoded like logger writes the message directly.
+func assertSummary(t *testing.T, logger *Logger, out *memoryAppender, summary string, repeated int) {
+	t.Helper()
+	logger.Warnw(fmt.Sprintf("previous message repeated %d times", repeated), "repeated", repeated)
+	lines := out.lines()
+	if len(lines) != 1 {
+		t.Fatalf("lines %q", lines)
+	}
+	if got, want := dedupTime.ReplaceAllString(summary, "T"), dedupTime.ReplaceAllString(lines[0], "T"); got != want {
+		t.Errorf("summary %q\nwant    %q", got, want)
+	}
+}
+
+func TestDedupRunSummary(t *testing.T) {
+	for _, impl := range testBackends {
+		for _, formatter := range []string{"normal", "json", "pattern"} {
+			logger, dedup, out := testDedupLogger(t, impl, formatter, "1h")
+			for i := 0; i < 3; i++ {
+				logger.Warnw("retrying", "i", i)
+			}
+			logger.Info("other")
+			_ = dedup.Flush()
+			lines := out.lines()
+			if len(lines) != 3 || !strings.Contains(lines[0], "retrying") || !strings.Contains(lines[2], "other") {
+				t.Fatalf("%s %s: lines %q", impl, formatter, lines)
+			}
+			assertSummary(t, logger, out, lines[1], 2)
+		}
+	}
+}
+
+// the summary is encoded by the goroutine of the appender, the entry ending the run is queued behind it
+func TestDedupSummaryEncodedByTheAppender(t *testing.T) {
+	out := &memoryAppender{}
+	appender, err := newDedupAppender(map[string]string{"window": "1h"}, []Appender{out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := appender.(*dedupAppender)
+	defer a.Close()
+	release := make(chan struct{})
+	encode := func(entry *Entry) []byte {
+		<-release
+		return []byte(entry.Message + "\n")
+	}
+	for i := 0; i < 2; i++ {
+		_, _ = a.WriteEntry(&Entry{Logger: "a", Level: LvlWarn, Message: "retrying", encode: encode}, []byte("retrying\n"))
+	}
+	returned := make(chan struct{})
+	go func() {
+		_, _ = a.WriteEntry(&Entry{Logger: "a", Level: LvlInfo, Message: "other"}, []byte("other\n"))
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		break
+	case <-time.After(5 * time.Second):
+		t.Error("the entry ending the run waits for the summary")
+	}
+	close(release)
+	<-returned
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Join(out.lines(), ","); lines != "retrying,previous message repeated 1 times,other" {
+		t.Errorf("lines %s", lines)
+	}
+}
+
+func TestDedupWindowClose(t *testing.T) {
+	for _, impl := range testBackends {
+		logger, _, out := testDedupLogger(t, impl, "json", "50ms")
+		logger.Warn("retrying")
+		logger.Warn("retrying")
+		var lines []string
+		for deadline := time.Now().Add(5 * time.Second); len(lines) < 2 && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+			lines = append(lines, out.lines()...)
+		}
+		if len(lines) != 2 {
+			t.Fatalf("%s: lines %q", impl, lines)
+		}
+		assertSummary(t, logger, out, lines[1], 1)
+
+		// the window is over, the next entry starts a new run
+		logger.Warn("retrying")
+		if lines := out.lines(); len(lines) != 1 {
+			t.Errorf("%s: lines %q after the window", impl, lines)
+		}
+	}
+}
+
+func TestDedupFlushAndClose(t *testing.T) {
+	for _, impl := range testBackends {
+		logger, dedup, out := testDedupLogger(t, impl, "normal", "1h")
+		logger.Warn("retrying")
+		logger.Warn("retrying")
+		if err := dedup.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		lines := out.lines()
+		if len(lines) != 2 {
+			t.Fatalf("%s: lines %q after Flush", impl, lines)
+		}
+		assertSummary(t, logger, out, lines[1], 1)
+
+		logger.Warn("retrying")
+		logger.Warn("retrying")
+		logger.Warn("retrying")
+		if err := dedup.Close(); err != nil {
+			t.Fatal(err)
+		}
+		lines = out.lines()
+		if len(lines) != 2 {
+			t.Fatalf("%s: lines %q after Close", impl, lines)
+		}
+		assertSummary(t, logger, out, lines[1], 2)
+		if out.closed {
+			t.Errorf("%s: wrapped appender closed", impl)
+		}
+
+		// closed, the entries are not collapsed anymore
+		logger.Info("again")
+		logger.Info("again")
+		if lines := out.lines(); len(lines) != 2 {
+			t.Errorf("%s: lines %q after Close", impl, lines)
+		}
+	}
+}
@@ -1,19 +1,28 @@
 package factory
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type fileAppenderOptions struct {
-	LogFileDir     string `yaml:"log-file-dir"`
-	LogFileName    string `yaml:"log-file-name"`
-	MaxFileSize    int    `yaml:"max-file-size"`
-	MaxFileBackups int    `yaml:"max-file-backups"`
-	MaxFileAge     string `yaml:"max-file-age"` // 秒
-	LocalTime      bool   `yaml:"local-time"`
-	Compress       bool   `yaml:"compress"`
+	LogFileDir      string `yaml:"log-file-dir"`
+	LogFileName     string `yaml:"log-file-name"`
+	MaxFileSize     int    `yaml:"max-file-size"`
+	MaxFileBackups  int    `yaml:"max-file-backups"`
+	MaxFileAge      string `yaml:"max-file-age"` // 秒
+	LocalTime       bool   `yaml:"local-time"`
+	Compress        bool   `yaml:"compress"`
+	RotateBy        string `yaml:"rotate-by"`         // size | time | size-and-time
+	RotateSchedule  string `yaml:"rotate-schedule"`   // hourly | daily | cron expression
+	FileNamePattern string `yaml:"file-name-pattern"` // application-%d{yyyy-MM-dd}.%i.log.gz
+	MaxTotalSize    string `yaml:"max-total-size"`    // 字节
 }
 
 var fileAppenderOptionKeyLogFileDir = "log-file-dir"
@@ -23,32 +32,155 @@ var fileAppenderOptionKeyMaxFileBackups = "max-file-backups"
 var fileAppenderOptionKeyMaxFileAge = "max-file-age"
 var fileAppenderOptionKeyLocalTime = "local-time"
 var fileAppenderOptionKeyCompress = "compress"
+var fileAppenderOptionKeyRotateBy = "rotate-by"
+var fileAppenderOptionKeyRotateSchedule = "rotate-schedule"
+var fileAppenderOptionKeyFileNamePattern = "file-name-pattern"
+var fileAppenderOptionKeyMaxTotalSize = "max-total-size"
 
 type fileWriterConfig struct {
-	LogFilePath    string
-	MaxFileSize    int
-	MaxFileBackups int
-	MaxFileAge     time.Duration
-	LocalTime      bool
-	Compress       bool
-}
-
-func toFileWriterConfig(appender AppenderConfig) *fileWriterConfig {
-	vLogFileDir := appender.Options[fileAppenderOptionKeyLogFileDir]
-	vLogFileName := appender.Options[fileAppenderOptionKeyLogFileName]
-	vMaxFileSize, _ := strconv.Atoi(appender.Options[fileAppenderOptionKeyMaxFileSize])
-	vMaxFileBackups, _ := strconv.Atoi(appender.Options[fileAppenderOptionKeyMaxFileBackups])
-	vMaxFileAge, _ := appender.Options[fileAppenderOptionKeyMaxFileAge]
-	vLocalTime, _ := strconv.ParseBool(appender.Options[fileAppenderOptionKeyLocalTime])
-	vCompress, _ := strconv.ParseBool(appender.Options[fileAppenderOptionKeyCompress])
+	LogFilePath     string
+	MaxFileSize     int64
+	MaxFileBackups  int
+	MaxFileAge      time.Duration
+	LocalTime       bool
+	Compress        bool
+	RotateBy        rotateBy
+	RotateSchedule  string
+	FileNamePattern string
+	MaxTotalSize    int64
+}
+
+type fileAppender struct {
+	config *fileWriterConfig
+	out    io.WriteCloser
+	lk     sync.RWMutex // Close waits for the writes in flight
+	closed bool
+}
+
+func newFileAppender(options map[string]string) (Appender, error) {
+	config, err := toFileWriterConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	var out io.WriteCloser
+	if config.RotateBy == rotateBySize && len(config.FileNamePattern) == 0 && config.MaxTotalSize <= 0 {
+		out, err = newLumberjackWriter(config)
+	} else {
+		out, err = newRollingWriter(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	addActiveLogFile(config.LogFilePath)
+	return &fileAppender{
+		config: config,
+		out:    out,
+	}, nil
+}
+
+// activeLogFiles counts the appenders writing each file, the rolling writers never prune them.
+var activeLogFiles = make(map[string]int)
+var activeLogFilesLk = &sync.Mutex{}
+
+func activeLogFileKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+func addActiveLogFile(path string) {
+	activeLogFilesLk.Lock()
+	activeLogFiles[activeLogFileKey(path)]++
+	activeLogFilesLk.Unlock()
+}
+
+func removeActiveLogFile(path string) {
+	key := activeLogFileKey(path)
+	activeLogFilesLk.Lock()
+	if activeLogFiles[key]--; activeLogFiles[key] <= 0 {
+		delete(activeLogFiles, key)
+	}
+	activeLogFilesLk.Unlock()
+}
+
+func isActiveLogFile(path string) bool {
+	key := activeLogFileKey(path)
+	activeLogFilesLk.Lock()
+	defer activeLogFilesLk.Unlock()
+	return activeLogFiles[key] > 0
+}
+
+// Write fails with os.ErrClosed after Close, e.g. for a logger still holding its config
+// while Reload closes the appender, lumberjack would reopen the file otherwise.
+func (a *fileAppender) Write(p []byte) (int, error) {
+	a.lk.RLock()
+	defer a.lk.RUnlock()
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+	return a.out.Write(p)
+}
+func (a *fileAppender) Flush() error {
+	if syncer, ok := a.out.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// Health reports the error of the last rotation.
+func (a *fileAppender) Health() error {
+	if health, ok := a.out.(interface{ Health() error }); ok {
+		return health.Health()
+	}
+	return nil
+}
+func (a *fileAppender) Close() error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	removeActiveLogFile(a.config.LogFilePath)
+	return a.out.Close()
+}
+func (a *fileAppender) Name() string {
+	return "file:" + a.config.LogFilePath
+}
+
+func toFileWriterConfig(appenderOptions map[string]string) (*fileWriterConfig, error) {
+	vLogFileDir := appenderOptions[fileAppenderOptionKeyLogFileDir]
+	vLogFileName := appenderOptions[fileAppenderOptionKeyLogFileName]
+	vMaxFileSize, err := fileIntOption(appenderOptions, fileAppenderOptionKeyMaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+	vMaxFileBackups, err := fileIntOption(appenderOptions, fileAppenderOptionKeyMaxFileBackups)
+	if err != nil {
+		return nil, err
+	}
+	vMaxFileAge, _ := appenderOptions[fileAppenderOptionKeyMaxFileAge]
+	vLocalTime, err := fileBoolOption(appenderOptions, fileAppenderOptionKeyLocalTime)
+	if err != nil {
+		return nil, err
+	}
+	vCompress, err := fileBoolOption(appenderOptions, fileAppenderOptionKeyCompress)
+	if err != nil {
+		return nil, err
+	}
 	options := &fileAppenderOptions{
-		LogFileDir:     vLogFileDir,
-		LogFileName:    vLogFileName,
-		MaxFileSize:    vMaxFileSize,
-		MaxFileBackups: vMaxFileBackups,
-		MaxFileAge:     vMaxFileAge,
-		LocalTime:      vLocalTime,
-		Compress:       vCompress,
+		LogFileDir:      vLogFileDir,
+		LogFileName:     vLogFileName,
+		MaxFileSize:     vMaxFileSize,
+		MaxFileBackups:  vMaxFileBackups,
+		MaxFileAge:      vMaxFileAge,
+		LocalTime:       vLocalTime,
+		Compress:        vCompress,
+		RotateBy:        appenderOptions[fileAppenderOptionKeyRotateBy],
+		RotateSchedule:  appenderOptions[fileAppenderOptionKeyRotateSchedule],
+		FileNamePattern: appenderOptions[fileAppenderOptionKeyFileNamePattern],
+		MaxTotalSize:    appenderOptions[fileAppenderOptionKeyMaxTotalSize],
 	}
 	logFileDir := strings.TrimSpace(options.LogFileDir)
 	if len(logFileDir) <= 0 {
@@ -59,14 +191,69 @@ func toFileWriterConfig(appender AppenderConfig) *fileWriterConfig {
 		logFileName = "./application.log"
 	}
 	logFilePath := logFileDir + SLASH + logFileName
-	maxFileAge, _ := time.ParseDuration(options.MaxFileAge)
+	var maxFileAge time.Duration
+	if value := strings.TrimSpace(options.MaxFileAge); len(value) > 0 {
+		if maxFileAge, err = time.ParseDuration(value); err != nil || maxFileAge < 0 {
+			return nil, fmt.Errorf("invalid %s %q", fileAppenderOptionKeyMaxFileAge, value)
+		}
+	}
+	maxFileSize := int64(options.MaxFileSize)
+	if maxFileSize <= 0 {
+		maxFileSize = defaultMaxFileSize
+	}
+	var maxTotalSize int64
+	if value := strings.TrimSpace(options.MaxTotalSize); len(value) > 0 {
+		if maxTotalSize, err = strconv.ParseInt(value, 10, 64); err != nil || maxTotalSize < 0 {
+			return nil, fmt.Errorf("invalid %s %q", fileAppenderOptionKeyMaxTotalSize, value)
+		}
+	}
+	var rotate rotateBy
+	switch by := strings.ToLower(strings.TrimSpace(options.RotateBy)); by {
+	case "", string(rotateBySize):
+		rotate = rotateBySize
+		break
+	case string(rotateByTime), string(rotateBySizeAndTime):
+		rotate = rotateBy(by)
+		break
+	default:
+		return nil, fmt.Errorf("invalid rotate-by %q", options.RotateBy)
+	}
 
 	return &fileWriterConfig{
-		LogFilePath:    logFilePath,
-		MaxFileSize:    options.MaxFileSize,
-		MaxFileBackups: options.MaxFileBackups,
-		MaxFileAge:     maxFileAge,
-		LocalTime:      options.LocalTime,
-		Compress:       options.Compress,
+		LogFilePath:     logFilePath,
+		MaxFileSize:     maxFileSize,
+		MaxFileBackups:  options.MaxFileBackups,
+		MaxFileAge:      maxFileAge,
+		LocalTime:       options.LocalTime,
+		Compress:        options.Compress,
+		RotateBy:        rotate,
+		RotateSchedule:  options.RotateSchedule,
+		FileNamePattern: options.FileNamePattern,
+		MaxTotalSize:    maxTotalSize,
+	}, nil
+}
+
+// fileIntOption parses a size or count option, 0 when it is empty.
+func fileIntOption(options map[string]string, key string) (int, error) {
+	value := strings.TrimSpace(options[key])
+	if len(value) == 0 {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return i, nil
+}
+
+func fileBoolOption(options map[string]string, key string) (bool, error) {
+	value := strings.TrimSpace(options[key])
+	if len(value) == 0 {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q", key, value)
 	}
+	return b, nil
 }
@@ -0,0 +1,367 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var kafkaAppenderOptionKeyBrokers = "brokers"
+var kafkaAppenderOptionKeyTopic = "topic"
+var kafkaAppenderOptionKeyKeyField = "key-field"
+var kafkaAppenderOptionKeyAcks = "acks"
+var kafkaAppenderOptionKeyBatchSize = "batch-size"
+var kafkaAppenderOptionKeyLinger = "linger"
+var kafkaAppenderOptionKeyCompression = "compression"
+var kafkaAppenderOptionKeyBufferSize = "buffer-size"
+
+const defaultKafkaBatchSize = 100
+const defaultKafkaLinger = 10 * time.Millisecond
+const defaultKafkaBufferSize = 10000
+const kafkaWriteTimeout = 10 * time.Second
+const kafkaMaxBackoff = 30 * time.Second
+const kafkaFlushTimeout = 5 * time.Second
+
+// kafkaProducer is the part of kafka.Writer used by the appender.
+type kafkaProducer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaAppender sends every entry as a message to a topic.
+// Entries are queued in a bounded buffer drained by a background goroutine, so an
+// unreachable broker never blocks the logging goroutine; once the buffer is full
+// the oldest entries are dropped.
+type kafkaAppender struct {
+	topic        string
+	keyField     string
+	batchSize    int
+	producer     kafkaProducer
+	flushTimeout time.Duration
+	ctx          context.Context // of every delivery, cancelled flushTimeout after Close
+	cancel       context.CancelFunc
+
+	lk       sync.Mutex
+	cond     *sync.Cond
+	queue    []kafka.Message
+	capacity int
+	sending  int // messages taken from queue and not yet delivered
+	dropped  uint64
+	lastErr  error
+	closed   bool
+	closing  chan struct{}
+	done     chan struct{}
+}
+
+func newKafkaAppender(options map[string]string) (Appender, error) {
+	brokers := splitList(options[kafkaAppenderOptionKeyBrokers])
+	if len(brokers) == 0 {
+		return nil, errors.New("option brokers is required")
+	}
+	topic := strings.TrimSpace(options[kafkaAppenderOptionKeyTopic])
+	if len(topic) == 0 {
+		return nil, errors.New("option topic is required")
+	}
+	acks, err := kafkaAcks(options[kafkaAppenderOptionKeyAcks])
+	if err != nil {
+		return nil, err
+	}
+	compression, err := kafkaCompression(options[kafkaAppenderOptionKeyCompression])
+	if err != nil {
+		return nil, err
+	}
+	batchSize, err := intOption(options, kafkaAppenderOptionKeyBatchSize, defaultKafkaBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	bufferSize, err := intOption(options, kafkaAppenderOptionKeyBufferSize, defaultKafkaBufferSize)
+	if err != nil {
+		return nil, err
+	}
+	linger, err := durationOption(options, kafkaAppenderOptionKeyLinger, defaultKafkaLinger)
+	if err != nil {
+		return nil, err
+	}
+	producer := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: acks,
+		BatchSize:    batchSize,
+		BatchTimeout: linger,
+		Compression:  compression,
+		MaxAttempts:  1, // retried by the appender, keeping the entries buffered meanwhile
+		WriteTimeout: kafkaWriteTimeout,
+	}
+	return newKafkaAppenderWith(producer, topic, options[kafkaAppenderOptionKeyKeyField], batchSize, bufferSize), nil
+}
+
+func newKafkaAppenderWith(producer kafkaProducer, topic, keyField string, batchSize, bufferSize int) *kafkaAppender {
+	a := &kafkaAppender{
+		topic:        topic,
+		keyField:     strings.TrimSpace(keyField),
+		batchSize:    batchSize,
+		producer:     producer,
+		flushTimeout: kafkaFlushTimeout,
+		capacity:     bufferSize,
+		closing:      make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	a.cond = sync.NewCond(&a.lk)
+	go a.loop()
+	return a
+}
+
+func (a *kafkaAppender) Write(p []byte) (int, error) {
+	value := make([]byte, len(bytes.TrimRight(p, "\n")))
+	copy(value, p)
+	msg := kafka.Message{Value: value} // the key is read by loop
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if a.closed {
+		return 0, errors.New("kafka appender closed")
+	}
+	if len(a.queue) >= a.capacity {
+		a.queue = a.queue[1:]
+		a.dropped++
+	}
+	a.queue = append(a.queue, msg)
+	a.cond.Broadcast()
+	return len(p), nil
+}
+
+// key reads the key-field of a json encoded entry, entries of other formatters have no key.
+// It is called by loop, never on the logging goroutine.
+func (a *kafkaAppender) key(value []byte) []byte {
+	if len(a.keyField) == 0 || len(value) == 0 || value[0] != '{' {
+		return nil
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil
+	}
+	raw, exists := fields[a.keyField]
+	if !exists {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []byte(s)
+	}
+	return []byte(raw)
+}
+
+func (a *kafkaAppender) loop() {
+	defer close(a.done)
+	backoff := time.Duration(0)
+	for {
+		a.lk.Lock()
+		for len(a.queue) == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if len(a.queue) == 0 && a.closed {
+			a.lk.Unlock()
+			return
+		}
+		n := len(a.queue)
+		if n > a.batchSize {
+			n = a.batchSize
+		}
+		batch := make([]kafka.Message, n)
+		copy(batch, a.queue[:n])
+		a.queue = a.queue[n:]
+		a.sending = n
+		closed := a.closed
+		a.lk.Unlock()
+
+		for i := range batch {
+			if batch[i].Key == nil {
+				batch[i].Key = a.key(batch[i].Value)
+			}
+		}
+		err := a.producer.WriteMessages(a.ctx, batch...)
+
+		a.lk.Lock()
+		a.sending = 0
+		a.lastErr = err
+		if err != nil && !closed {
+			a.requeue(batch)
+		} else if err != nil {
+			// closing, the broker is unreachable or the flush deadline passed
+			a.dropped += uint64(len(batch) + len(a.queue))
+			a.queue = nil
+		}
+		a.cond.Broadcast()
+		a.lk.Unlock()
+
+		if err == nil || closed {
+			backoff = 0
+			continue
+		}
+		if backoff == 0 {
+			backoff = 100 * time.Millisecond
+		} else if backoff < kafkaMaxBackoff {
+			backoff *= 2
+		}
+		a.wait(backoff)
+	}
+}
+
+// requeue puts an undelivered batch back in front of the queue, dropping the oldest
+// entries beyond the capacity. Must be called with lk held.
+func (a *kafkaAppender) requeue(batch []kafka.Message) {
+	queue := make([]kafka.Message, 0, len(batch)+len(a.queue))
+	queue = append(queue, batch...)
+	queue = append(queue, a.queue...)
+	if over := len(queue) - a.capacity; over > 0 {
+		queue = queue[over:]
+		a.dropped += uint64(over)
+	}
+	a.queue = queue
+}
+
+// wait sleeps for the backoff unless the appender is closed meanwhile.
+func (a *kafkaAppender) wait(backoff time.Duration) {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-a.closing:
+	}
+}
+
+// Flush waits until the buffered entries are delivered, or fails after kafkaFlushTimeout.
+func (a *kafkaAppender) Flush() error {
+	deadline := time.Now().Add(kafkaFlushTimeout)
+	for {
+		a.lk.Lock()
+		pending := len(a.queue) + a.sending
+		lastErr := a.lastErr
+		a.lk.Unlock()
+		if pending == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("kafka appender: %d entries not delivered: %v", pending, lastErr)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Close delivers the buffered entries within kafkaFlushTimeout overall, the entries left
+// when the deadline passes or a delivery fails are dropped.
+func (a *kafkaAppender) Close() error {
+	a.lk.Lock()
+	if a.closed {
+		a.lk.Unlock()
+		return nil
+	}
+	a.closed = true
+	dropped := a.dropped
+	close(a.closing)
+	a.cond.Broadcast()
+	a.lk.Unlock()
+	deadline := time.AfterFunc(a.flushTimeout, a.cancel)
+	<-a.done
+	deadline.Stop()
+	a.cancel()
+	a.lk.Lock()
+	dropped = a.dropped - dropped
+	lastErr := a.lastErr
+	a.lk.Unlock()
+	if err := a.producer.Close(); err != nil {
+		return err
+	}
+	if dropped > 0 {
+		return fmt.Errorf("kafka appender: %d entries not delivered: %v", dropped, lastErr)
+	}
+	return nil
+}
+
+func (a *kafkaAppender) Name() string {
+	return "kafka:" + a.topic
+}
+
+// Health reports the last delivery error, nil once the broker accepts messages again.
+func (a *kafkaAppender) Health() error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.lastErr
+}
+
+// Dropped returns the number of entries dropped because the buffer was full.
+func (a *kafkaAppender) Dropped() uint64 {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.dropped
+}
+
+func kafkaAcks(acks string) (kafka.RequiredAcks, error) {
+	switch strings.ToLower(strings.TrimSpace(acks)) {
+	case "", "all", "-1":
+		return kafka.RequireAll, nil
+	case "one", "1":
+		return kafka.RequireOne, nil
+	case "none", "0":
+		return kafka.RequireNone, nil
+	}
+	return 0, fmt.Errorf("invalid acks %q", acks)
+}
+
+func kafkaCompression(compression string) (kafka.Compression, error) {
+	switch strings.ToLower(strings.TrimSpace(compression)) {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	}
+	return 0, fmt.Errorf("invalid compression %q", compression)
+}
+
+func splitList(list string) []string {
+	elements := make([]string, 0)
+	for _, e := range strings.Split(list, ",") {
+		if e = strings.TrimSpace(e); len(e) > 0 {
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+func intOption(options map[string]string, key string, def int) (int, error) {
+	value := strings.TrimSpace(options[key])
+	if len(value) == 0 {
+		return def, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return i, nil
+}
+
+func durationOption(options map[string]string, key string, def time.Duration) (time.Duration, error) {
+	value := strings.TrimSpace(options[key])
+	if len(value) == 0 {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,159 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockProducer records the delivered messages, failing while down is set.
+type mockProducer struct {
+	lk        sync.Mutex
+	down      bool
+	block     bool // a failing write waits for its context like an unreachable broker
+	delivered []kafka.Message
+	attempts  int
+	closed    bool
+}
+
+func (p *mockProducer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	p.lk.Lock()
+	p.attempts++
+	down, block := p.down, p.block
+	if !down {
+		p.delivered = append(p.delivered, msgs...)
+	}
+	p.lk.Unlock()
+	if !down {
+		return nil
+	}
+	if block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return errors.New("broker down")
+}
+
+func (p *mockProducer) Close() error {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.closed = true
+	return nil
+}
+
+func (p *mockProducer) setDown(down bool) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.down = down
+}
+
+func (p *mockProducer) messages() []kafka.Message {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return append([]kafka.Message(nil), p.delivered...)
+}
+
+func TestKafkaAppenderDeliversWithKeys(t *testing.T) {
+	producer := &mockProducer{}
+	a := newKafkaAppenderWith(producer, "logs", "logger", 2, 10)
+	_, _ = a.Write([]byte(`{"logger":"a/b","msg":"one"}` + "\n"))
+	_, _ = a.Write([]byte(`{"logger":42,"msg":"two"}` + "\n"))
+	_, _ = a.Write([]byte("plain text\n"))
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	msgs := producer.messages()
+	if len(msgs) != 3 {
+		t.Fatalf("delivered %d messages, want 3", len(msgs))
+	}
+	want := []struct{ key, value string }{
+		{"a/b", `{"logger":"a/b","msg":"one"}`},
+		{"42", `{"logger":42,"msg":"two"}`},
+		{"", "plain text"},
+	}
+	for i, w := range want {
+		if string(msgs[i].Key) != w.key || string(msgs[i].Value) != w.value {
+			t.Errorf("message %d key %q value %q, want %q %q", i, msgs[i].Key, msgs[i].Value, w.key, w.value)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !producer.closed {
+		t.Error("producer not closed")
+	}
+	if _, err := a.Write([]byte("late")); err == nil {
+		t.Error("write after close succeeded")
+	}
+}
+
+func TestKafkaAppenderKeepsEntriesWhileBrokerDown(t *testing.T) {
+	producer := &mockProducer{down: true}
+	a := newKafkaAppenderWith(producer, "logs", "", 10, 3)
+	for _, m := range []string{"1", "2", "3", "4", "5"} {
+		_, _ = a.Write([]byte(m))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for a.Health() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if a.Health() == nil {
+		t.Fatal("no delivery error reported")
+	}
+	producer.setDown(false)
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	msgs := producer.messages()
+	if len(msgs) != 3 || string(msgs[0].Value) != "3" || string(msgs[2].Value) != "5" {
+		t.Errorf("delivered %v, want the newest 3", msgs)
+	}
+	if a.Dropped() != 2 {
+		t.Errorf("dropped %d, want 2", a.Dropped())
+	}
+	if a.Health() != nil {
+		t.Errorf("health %v after recovery", a.Health())
+	}
+	_ = a.Close()
+}
+
+func TestKafkaAppenderCloseIsBounded(t *testing.T) {
+	producer := &mockProducer{down: true, block: true}
+	a := newKafkaAppenderWith(producer, "logs", "", 1, 100)
+	a.flushTimeout = 100 * time.Millisecond
+	for i := 0; i < 50; i++ {
+		_, _ = a.Write([]byte("entry"))
+	}
+	start := time.Now()
+	err := a.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("close took %s", elapsed)
+	}
+	if err == nil {
+		t.Error("close reported no undelivered entries")
+	}
+	if a.Dropped() != 50 {
+		t.Errorf("dropped %d, want 50", a.Dropped())
+	}
+	if len(producer.messages()) != 0 {
+		t.Error("messages delivered while down")
+	}
+}
+
+func TestKafkaAppenderOptions(t *testing.T) {
+	for _, options := range []map[string]string{
+		{"topic": "logs"},
+		{"brokers": "localhost:9092"},
+		{"brokers": "localhost:9092", "topic": "logs", "acks": "some"},
+		{"brokers": "localhost:9092", "topic": "logs", "compression": "rar"},
+		{"brokers": "localhost:9092", "topic": "logs", "batch-size": "0"},
+		{"brokers": "localhost:9092", "topic": "logs", "linger": "soon"},
+	} {
+		if _, err := newKafkaAppender(options); err == nil {
+			t.Errorf("options %v accepted", options)
+		}
+	}
+}
@@ -1,10 +1,8 @@
 package factory
 
 import (
-	"fmt"
 	"github.com/natefinch/lumberjack/v3"
 	"io"
-	"os"
 	"time"
 )
 
@@ -25,17 +23,16 @@ func defaultLumberjackWriter(filePath string) io.Writer {
 	return writer
 }
 
-func newLumberjackWriter(config *fileWriterConfig) io.Writer {
+func newLumberjackWriter(config *fileWriterConfig) (io.WriteCloser, error) {
 	options := lumberjack.Options{
 		MaxAge:     config.MaxFileAge,
 		MaxBackups: config.MaxFileBackups,
 		LocalTime:  config.LocalTime,
 		Compress:   config.Compress,
 	}
-	writer, err := lumberjack.NewRoller(config.LogFilePath, defaultMaxFileSize, &options)
+	writer, err := lumberjack.NewRoller(config.LogFilePath, config.MaxFileSize, &options)
 	if err != nil {
-		fmt.Println(fmt.Sprintf("Fatal! %s", err.Error()))
-		os.Exit(-1)
+		return nil, err
 	}
-	return writer
+	return writer, nil
 }
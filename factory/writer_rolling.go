@@ -0,0 +1,560 @@
+package factory
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type rotateBy string
+
+const (
+	rotateBySize        rotateBy = "size"
+	rotateByTime        rotateBy = "time"
+	rotateBySizeAndTime rotateBy = "size-and-time"
+)
+
+// rollingWriter writes to LogFilePath and renames it to a backup named by the
+// file-name pattern when the schedule fires, or when it exceeds MaxFileSize with size
+// rotation. Backups are compressed and pruned in the background.
+type rollingWriter struct {
+	config   *fileWriterConfig
+	location *time.Location
+	schedule rollingSchedule // nil with rotate-by size
+	pattern  *rollingFileNamePattern
+	now      func() time.Time
+
+	lk           sync.Mutex
+	file         *os.File
+	size         int64
+	periodStart  time.Time // names the backup of the current file
+	nextRotation time.Time
+	lastErr      error // of the last rotation
+	closed       bool
+	maintainLk   sync.Mutex
+	maintaining  sync.WaitGroup
+}
+
+func newRollingWriter(config *fileWriterConfig) (*rollingWriter, error) {
+	w := &rollingWriter{
+		config:   config,
+		location: time.UTC,
+		now:      time.Now,
+	}
+	if config.LocalTime {
+		w.location = time.Local
+	}
+	if config.RotateBy != rotateBySize {
+		schedule, err := newRollingSchedule(config.RotateSchedule)
+		if err != nil {
+			return nil, err
+		}
+		w.schedule = schedule
+	}
+	pattern, err := newRollingFileNamePattern(config)
+	if err != nil {
+		return nil, err
+	}
+	w.pattern = pattern
+	return w, nil
+}
+
+func (w *rollingWriter) Write(p []byte) (int, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	now := w.now().In(w.location)
+	if w.schedule != nil && !now.Before(w.nextRotation) {
+		if err := w.rotate(now); err != nil {
+			return 0, err
+		}
+	} else if w.config.RotateBy != rotateByTime && w.size > 0 && w.size+int64(len(p)) > w.config.MaxFileSize {
+		if err := w.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// open must be called with lk held.
+func (w *rollingWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.config.LogFilePath), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.config.LogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	w.periodStart = w.now().In(w.location)
+	if w.size > 0 {
+		// the entries of an existing file belong to the period it was last written in
+		w.periodStart = info.ModTime().In(w.location)
+	}
+	if w.schedule != nil {
+		w.nextRotation = w.schedule.next(w.periodStart)
+	}
+	return nil
+}
+
+// rotate must be called with lk held.
+// An error closing the file is reported by Health, the entries go to the new file anyway.
+func (w *rollingWriter) rotate(now time.Time) error {
+	closeErr := w.file.Close()
+	w.file = nil
+	named := w.periodStart
+	if w.schedule == nil {
+		named = now
+	}
+	backup := w.pattern.backupPath(named)
+	err := os.MkdirAll(filepath.Dir(backup), 0755)
+	if err == nil {
+		err = os.Rename(w.config.LogFilePath, backup)
+	}
+	w.lastErr = errors.Join(closeErr, err)
+	if err == nil {
+		w.maintaining.Add(1)
+		go w.maintain(backup, now)
+	}
+	// the entries keep going to the active file when the backup could not be made
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.periodStart = now
+	if w.schedule != nil {
+		w.nextRotation = w.schedule.next(now)
+	}
+	return nil
+}
+
+// maintain compresses a backup and removes the backups beyond the retention,
+// now is the time of the rotation.
+func (w *rollingWriter) maintain(backup string, now time.Time) {
+	defer w.maintaining.Done()
+	w.maintainLk.Lock()
+	defer w.maintainLk.Unlock()
+	if w.pattern.compress {
+		_ = compressFile(backup, backup+".gz")
+	}
+	w.prune(now)
+}
+
+func compressFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(dst + ".tmp")
+		return err
+	}
+	if err = os.Rename(dst+".tmp", dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// prune keeps the newest backups within max-file-backups, max-file-age and max-total-size,
+// it only removes the names of the file name pattern and never a file an appender writes.
+func (w *rollingWriter) prune(now time.Time) {
+	if w.config.MaxFileBackups <= 0 && w.config.MaxFileAge <= 0 && w.config.MaxTotalSize <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(w.pattern.glob)
+	if err != nil {
+		return
+	}
+	backups := make([]os.FileInfo, 0, len(matches))
+	paths := make(map[os.FileInfo]string, len(matches))
+	own := activeLogFileKey(w.config.LogFilePath)
+	for _, match := range matches {
+		if !w.pattern.match.MatchString(match) || activeLogFileKey(match) == own || isActiveLogFile(match) {
+			continue
+		}
+		if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+			backups = append(backups, info)
+			paths[info] = match
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime().After(backups[j].ModTime())
+	})
+	cutoff := now.Add(-w.config.MaxFileAge)
+	var total int64
+	for i, info := range backups {
+		total += info.Size()
+		if w.config.MaxFileBackups > 0 && i >= w.config.MaxFileBackups ||
+			w.config.MaxFileAge > 0 && info.ModTime().Before(cutoff) ||
+			w.config.MaxTotalSize > 0 && total > w.config.MaxTotalSize {
+			_ = os.Remove(paths[info])
+		}
+	}
+}
+
+// Health reports the error of the last rotation.
+func (w *rollingWriter) Health() error {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	return w.lastErr
+}
+
+func (w *rollingWriter) Sync() error {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close closes the file, the writes afterwards fail with os.ErrClosed.
+func (w *rollingWriter) Close() error {
+	w.lk.Lock()
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.lk.Unlock()
+	w.maintaining.Wait()
+	return err
+}
+
+// rollingFileNamePattern names the backups like the fileNamePattern of logback,
+// e.g. application-%d{yyyy-MM-dd}.%i.log.gz, relative to the directory of the log file.
+type rollingFileNamePattern struct {
+	elements []func(buf *bytes.Buffer, t time.Time, index int)
+	hasIndex bool
+	compress bool
+	glob     string
+	match    *regexp.Regexp // the names of the backups, the glob matches other files too
+}
+
+func newRollingFileNamePattern(config *fileWriterConfig) (*rollingFileNamePattern, error) {
+	pattern := strings.TrimSpace(config.FileNamePattern)
+	if len(pattern) == 0 {
+		pattern = defaultRollingFileNamePattern(config)
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(config.LogFilePath), pattern)
+	}
+	p := &rollingFileNamePattern{}
+	if strings.HasSuffix(pattern, ".gz") {
+		p.compress = true
+		pattern = strings.TrimSuffix(pattern, ".gz")
+	} else if config.Compress {
+		p.compress = true
+	}
+	glob := strings.Builder{}
+	expr := strings.Builder{}
+	expr.WriteString("^")
+	hasDate := false
+	for i := 0; i < len(pattern); {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			literal := string(pattern[i])
+			p.elements = append(p.elements, func(buf *bytes.Buffer, t time.Time, index int) {
+				buf.WriteString(literal)
+			})
+			glob.WriteString(literal)
+			expr.WriteString(regexp.QuoteMeta(literal))
+			i++
+			continue
+		}
+		switch pattern[i+1] {
+		case 'i':
+			p.elements = append(p.elements, func(buf *bytes.Buffer, t time.Time, index int) {
+				buf.WriteString(strconv.Itoa(index))
+			})
+			p.hasIndex = true
+			expr.WriteString("[0-9]+")
+			i += 2
+			break
+		case 'd':
+			i += 2
+			option := ""
+			if i < len(pattern) && pattern[i] == '{' {
+				end := strings.IndexByte(pattern[i:], '}')
+				if end < 0 {
+					return nil, fmt.Errorf("missing } in file name pattern %q", pattern)
+				}
+				option = pattern[i+1 : i+end]
+				i += end + 1
+			}
+			if len(option) == 0 {
+				option = "yyyy-MM-dd"
+			}
+			format, err := newJavaDateFormat(option)
+			if err != nil {
+				return nil, err
+			}
+			date, err := javaDateRegexp(option)
+			if err != nil {
+				return nil, err
+			}
+			expr.WriteString(date)
+			if !strings.Contains(pattern, "%i") {
+				expr.WriteString(`(?:\.[0-9]+)?`)
+			}
+			p.elements = append(p.elements, func(buf *bytes.Buffer, t time.Time, index int) {
+				format.format(buf, t)
+				if index < 0 {
+					// a backup of the period exists already, the pattern has no %i
+					buf.WriteString("." + strconv.Itoa(-index))
+				}
+			})
+			hasDate = true
+			break
+		default:
+			return nil, fmt.Errorf("unknown conversion %%%c in file name pattern %q", pattern[i+1], pattern)
+		}
+		glob.WriteString("*")
+	}
+	if !hasDate {
+		return nil, errors.New("file name pattern needs a %d")
+	}
+	p.glob = glob.String()
+	if p.compress {
+		p.glob += ".gz"
+		expr.WriteString(`\.gz`)
+	}
+	expr.WriteString("$")
+	match, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	p.match = match
+	return p, nil
+}
+
+func defaultRollingFileNamePattern(config *fileWriterConfig) string {
+	name := filepath.Base(config.LogFilePath)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	date := "yyyy-MM-dd"
+	switch strings.ToLower(strings.TrimSpace(config.RotateSchedule)) {
+	case "hourly", "@hourly":
+		date = "yyyy-MM-dd-HH"
+		break
+	}
+	switch config.RotateBy {
+	case rotateBySize:
+		// the names of lumberjack
+		return base + "-%d{yyyy-MM-dd'T'HH-mm-ss.SSS}" + ext
+	case rotateBySizeAndTime:
+		return base + "-%d{" + date + "}.%i" + ext
+	}
+	return base + "-%d{" + date + "}" + ext
+}
+
+func (p *rollingFileNamePattern) render(t time.Time, index int) string {
+	buf := &bytes.Buffer{}
+	for _, e := range p.elements {
+		e(buf, t, index)
+	}
+	return buf.String()
+}
+
+// backupPath returns the first backup name of t not taken, compressed or not.
+func (p *rollingFileNamePattern) backupPath(t time.Time) string {
+	index := 1
+	if !p.hasIndex {
+		index = 0
+	}
+	for {
+		path := p.render(t, index)
+		if !fileExists(path) && !fileExists(path+".gz") {
+			return path
+		}
+		if p.hasIndex {
+			index++
+		} else {
+			index--
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// rollingSchedule returns the first rotation after t.
+type rollingSchedule interface {
+	next(t time.Time) time.Time
+}
+
+type hourlySchedule struct{}
+
+func (hourlySchedule) next(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+}
+
+type dailySchedule struct{}
+
+func (dailySchedule) next(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+}
+
+func newRollingSchedule(schedule string) (rollingSchedule, error) {
+	switch strings.ToLower(strings.TrimSpace(schedule)) {
+	case "", "daily", "@daily", "@midnight":
+		return dailySchedule{}, nil
+	case "hourly", "@hourly":
+		return hourlySchedule{}, nil
+	case "@weekly":
+		return newCronSchedule("0 0 * * 0")
+	case "@monthly":
+		return newCronSchedule("0 0 1 * *")
+	}
+	return newCronSchedule(schedule)
+}
+
+// cronSchedule is a cron expression of 5 fields: minute hour day-of-month month day-of-week,
+// fields accept *, lists, ranges and steps, e.g. "0 */6 * * 1-5".
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool
+}
+
+func newCronSchedule(expression string) (rollingSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q, hourly | daily | a cron expression of 5 fields", expression)
+	}
+	s := &cronSchedule{}
+	var err error
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := [5]*uint64{&s.minutes, &s.hours, &s.days, &s.months, &s.weekdays}
+	for i, field := range fields {
+		if *sets[i], err = cronField(field, bounds[i][0], bounds[i][1]); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expression, err)
+		}
+	}
+	if s.weekdays&(1<<7) != 0 {
+		// 7 is sunday too
+		s.weekdays |= 1
+	}
+	s.anyDay = fields[2] == "*"
+	s.anyWeekday = fields[4] == "*"
+	// 2000 is a leap year, a schedule not firing within 5 years from it never fires
+	if _, fires := s.nextWithin(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)); !fires {
+		return nil, fmt.Errorf("invalid schedule %q, it never fires", expression)
+	}
+	return s, nil
+}
+
+func cronField(field string, min int, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if slash := strings.IndexByte(part, '/'); slash >= 0 {
+			rangePart = part[:slash]
+			var err error
+			if step, err = strconv.Atoi(part[slash+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		from, to := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+	// like cron, a restricted day-of-month or day-of-week matches either one
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// next returns the first matching minute after t, or the time 5 years later when none matches
+// meanwhile, e.g. February 29 around 2100.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	next, _ := s.nextWithin(t)
+	return next
+}
+
+func (s *cronSchedule) nextWithin(t time.Time) (time.Time, bool) {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, true
+	}
+	return t, false
+}
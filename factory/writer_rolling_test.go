@@ -0,0 +1,231 @@
+package factory
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRollingWriter returns a writer of dir/app.log whose clock starts at start and advances by step per write.
+func testRollingWriter(t *testing.T, dir string, options map[string]string, start time.Time) (*rollingWriter, func(p string, step time.Duration)) {
+	t.Helper()
+	options["log-file-dir"] = dir
+	options["log-file-name"] = "app.log"
+	config, err := toFileWriterConfig(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := newRollingWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := start
+	w.now = func() time.Time { return now }
+	write := func(p string, step time.Duration) {
+		if _, err := w.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(step)
+	}
+	return w, write
+}
+
+// listFiles returns the files below dir with their content, gzip files with their size only.
+func listFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, _ := filepath.Rel(dir, path)
+		if strings.HasSuffix(name, ".gz") {
+			files[filepath.ToSlash(name)] = "gz"
+			return nil
+		}
+		content, _ := os.ReadFile(path)
+		files[filepath.ToSlash(name)] = string(content)
+		return nil
+	})
+	return files
+}
+
+func fileNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var rollingStart = time.Date(2026, 10, 18, 22, 50, 0, 0, time.UTC)
+
+func TestRollingWriterDaily(t *testing.T) {
+	dir := t.TempDir()
+	w, write := testRollingWriter(t, dir, map[string]string{"rotate-by": "time"}, rollingStart)
+	write("a\n", 30*time.Minute) // 22:50
+	write("b\n", 30*time.Minute)
+	write("c\n", 30*time.Minute) // 23:50
+	write("d\n", 30*time.Minute) // 00:20, rotated: the entries before midnight are in the backup
+	_ = w.Close()
+	files := listFiles(t, dir)
+	want := map[string]string{"app-2026-10-18.log": "a\nb\nc\n", "app.log": "d\n"}
+	if len(files) != len(want) {
+		t.Fatalf("files %v", fileNames(files))
+	}
+	for name, content := range want {
+		if files[name] != content {
+			t.Errorf("%s: %q, want %q", name, files[name], content)
+		}
+	}
+}
+
+func TestRollingWriterSizeAndTime(t *testing.T) {
+	dir := t.TempDir()
+	w, write := testRollingWriter(t, dir, map[string]string{
+		"rotate-by": "size-and-time", "max-file-size": "4", "compress": "true", "max-file-backups": "3",
+	}, rollingStart)
+	for i := 0; i < 6; i++ {
+		write("abc\n", time.Minute)
+	}
+	_ = w.Close()
+	// 5 backups of the day numbered by %i, compressed, 3 kept
+	names := fileNames(listFiles(t, dir))
+	if len(names) != 4 || names[3] != "app.log" {
+		t.Fatalf("files %v", names)
+	}
+	for _, name := range names[:3] {
+		if !strings.HasPrefix(name, "app-2026-10-18.") || !strings.HasSuffix(name, ".log.gz") {
+			t.Errorf("backup %s", name)
+		}
+	}
+}
+
+func TestRollingWriterPrune(t *testing.T) {
+	dir := t.TempDir()
+	w, _ := testRollingWriter(t, dir, map[string]string{
+		"rotate-by": "time", "rotate-schedule": "hourly", "max-file-age": "90m",
+	}, rollingStart)
+	// backups of 0 to 4 hours ago, the prune of the rotation at rollingStart keeps those of 0 and 1 hour ago
+	for age := 0; age < 5; age++ {
+		modTime := rollingStart.Add(-time.Duration(age) * time.Hour)
+		path := filepath.Join(dir, "app-"+modTime.Format("2006-01-02-15")+".log")
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.prune(rollingStart)
+	names := fileNames(listFiles(t, dir))
+	if strings.Join(names, ",") != "app-2026-10-18-21.log,app-2026-10-18-22.log" {
+		t.Errorf("files %v", names)
+	}
+}
+
+// the glob app-*.log of app.log matches the files of app-errors.log in the same directory too
+func TestRollingWriterPruneSharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	newAppender := func(name string) *fileAppender {
+		appender, err := newFileAppender(map[string]string{
+			"log-file-dir": dir, "log-file-name": name, "rotate-by": "time", "max-file-backups": "1",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = appender.Close() })
+		_, _ = appender.Write([]byte("x\n"))
+		return appender.(*fileAppender)
+	}
+	app := newAppender("app.log")
+	newAppender("app-errors.log")
+	for age, name := range []string{"app-2026-10-17.log", "app-errors-2026-10-17.log", "app-notes.log", "app-2026-10-16.log"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := rollingStart.Add(-time.Duration(age+1) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the active app-errors.log is the newest match, the backups of app.log are pruned to 1 regardless
+	modTime := rollingStart.Add(time.Hour)
+	_ = os.Chtimes(filepath.Join(dir, "app-errors.log"), modTime, modTime)
+	app.out.(*rollingWriter).prune(rollingStart)
+	names := fileNames(listFiles(t, dir))
+	want := "app-2026-10-17.log,app-errors-2026-10-17.log,app-errors.log,app-notes.log,app.log"
+	if strings.Join(names, ",") != want {
+		t.Errorf("files %v, want %s", names, want)
+	}
+}
+
+// the backups of size rotations are named by the time of the rotation
+func TestRollingWriterRotatesWhenCloseFails(t *testing.T) {
+	dir := t.TempDir()
+	w, write := testRollingWriter(t, dir, map[string]string{"max-file-size": "4", "file-name-pattern": "app-%d{HHmm}.log"}, rollingStart)
+	write("abc\n", time.Minute)
+	w.lk.Lock()
+	_ = w.file.Close() // the close of rotate fails
+	w.lk.Unlock()
+	write("def\n", time.Minute)
+	if w.Health() == nil {
+		t.Error("close error not reported")
+	}
+	write("ghi\n", time.Minute)
+	_ = w.Close()
+	files := listFiles(t, dir)
+	if files["app.log"] != "ghi\n" || files["app-2251.log"] != "abc\n" || files["app-2252.log"] != "def\n" {
+		t.Errorf("files %v", files)
+	}
+}
+
+func TestCronSchedule(t *testing.T) {
+	saturday := time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC)
+	for expression, want := range map[string][2]string{
+		"0 0 * * *":         {"2026-10-18 00:00", "2026-10-19 00:00"},
+		"*/15 9-17 * * 1-5": {"2026-10-19 09:00", "2026-10-19 09:15"},
+		"0 0 29 2 *":        {"2028-02-29 00:00", "2032-02-29 00:00"},
+		"30 4 1,15 * 5":     {"2026-10-23 04:30", "2026-10-30 04:30"},
+		"@weekly":           {"2026-10-18 00:00", "2026-10-25 00:00"},
+		"hourly":            {"2026-10-17 19:00", "2026-10-17 20:00"},
+	} {
+		s, err := newRollingSchedule(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := s.next(saturday)
+		second := s.next(first)
+		if got := [2]string{first.Format("2006-01-02 15:04"), second.Format("2006-01-02 15:04")}; got != want {
+			t.Errorf("%s: %v, want %v", expression, got, want)
+		}
+	}
+	for _, expression := range []string{"61 * * * *", "* * *", "x * * * *", "0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		if _, err := newRollingSchedule(expression); err == nil {
+			t.Errorf("%s accepted", expression)
+		}
+	}
+}
+
+func TestFileAppenderOptions(t *testing.T) {
+	for _, options := range []map[string]string{
+		{"max-file-size": "50MB"},
+		{"max-file-backups": "-1"},
+		{"max-file-age": "3 days"},
+		{"max-total-size": "10G"},
+		{"compress": "yes please"},
+		{"rotate-by": "weekly"},
+		{"rotate-by": "time", "file-name-pattern": "app.log"},
+		{"rotate-by": "time", "rotate-schedule": "0 0 30 2 *"},
+	} {
+		options["log-file-dir"] = t.TempDir()
+		if _, err := newFileAppender(options); err == nil {
+			t.Errorf("options %v accepted", options)
+		}
+	}
+}
@@ -0,0 +1,537 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var syslogAppenderOptionKeyNetwork = "network"
+var syslogAppenderOptionKeyAddress = "address"
+var syslogAppenderOptionKeyFacility = "facility"
+var syslogAppenderOptionKeyAppName = "app-name"
+var syslogAppenderOptionKeyHostname = "hostname"
+var syslogAppenderOptionKeyRfc = "rfc"
+var syslogAppenderOptionKeyFraming = "framing"
+var syslogAppenderOptionKeySdId = "sd-id"
+var syslogAppenderOptionKeyStructuredData = "structured-data"
+var syslogAppenderOptionKeyTlsCaFile = "tls-ca-file"
+var syslogAppenderOptionKeyTlsInsecure = "tls-insecure-skip-verify"
+var syslogAppenderOptionKeyBufferSize = "buffer-size"
+
+const defaultSyslogSdId = "lf4go@32473"
+const defaultSyslogBufferSize = 10000
+const syslogDialTimeout = 5 * time.Second
+const syslogWriteTimeout = 5 * time.Second
+const syslogMinBackoff = 100 * time.Millisecond
+const syslogMaxBackoff = 30 * time.Second
+const syslogFlushTimeout = 5 * time.Second
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverity follows the syslogEquivalent of log4j levels,
+// the levels below Debug are debug and the ones above Fatal emergency.
+func syslogSeverity(level LevelNum) int {
+	switch {
+	case level <= LvlDebug:
+		return 7 // debug
+	case level == LvlInfo:
+		return 6 // informational
+	case level == LvlWarn:
+		return 4 // warning
+	case level == LvlError:
+		return 3 // error
+	case level == LvlDPanic:
+		return 2 // critical
+	case level == LvlPanic:
+		return 1 // alert
+	}
+	return 0 // emergency
+}
+
+type syslogFraming string
+
+const (
+	syslogFramingNone          syslogFraming = "none"
+	syslogFramingOctetCounting syslogFraming = "octet-counting" // RFC 6587 3.4.1
+	syslogFramingNewline       syslogFraming = "newline"        // RFC 6587 3.4.2
+)
+
+// syslogAppender sends entries to a syslog server in RFC 5424 or RFC 3164 format,
+// over udp, tcp, tls (tcp+tls), unix or unixgram.
+// The encoded entry is the MSG part, the fields listed by structured-data
+// become SD-PARAMs of a single SD-ELEMENT with the sd-id.
+// Entries are queued in a bounded buffer sent by a background goroutine like the kafka
+// appender, so the logging goroutine never dials nor writes to the server. While the server
+// is unreachable the goroutine reconnects after a backoff doubling up to syslogMaxBackoff,
+// once the buffer is full the oldest entries are dropped.
+type syslogAppender struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	facility  int
+	appName   string
+	hostname  string
+	pid       string
+	rfc3164   bool
+	framing   syslogFraming
+	sdId      string
+	sdAll     bool
+	sdFields  map[string]bool
+
+	ctx    context.Context // of the dials, cancelled syslogFlushTimeout after Close
+	cancel context.CancelFunc
+
+	lk       sync.Mutex
+	cond     *sync.Cond
+	conn     net.Conn // set by loop, closed by Close too
+	queue    [][]byte
+	capacity int
+	sending  int // messages taken from queue and not yet sent
+	backoff  time.Duration
+	dropped  uint64
+	lastErr  error
+	closed   bool
+	closing  chan struct{}
+	done     chan struct{}
+}
+
+func newSyslogAppender(options map[string]string) (Appender, error) {
+	network := strings.ToLower(strings.TrimSpace(options[syslogAppenderOptionKeyNetwork]))
+	address := strings.TrimSpace(options[syslogAppenderOptionKeyAddress])
+	if len(network) == 0 {
+		network = "udp"
+		if len(address) == 0 {
+			network = "unixgram"
+		}
+	}
+	if len(address) == 0 {
+		switch network {
+		case "unix", "unixgram":
+			address = "/dev/log"
+		case "udp":
+			address = "127.0.0.1:514"
+		default:
+			return nil, fmt.Errorf("option address is required for network %s", network)
+		}
+	}
+	bufferSize, err := intOption(options, syslogAppenderOptionKeyBufferSize, defaultSyslogBufferSize)
+	if err != nil {
+		return nil, err
+	}
+	a := &syslogAppender{
+		network:  network,
+		address:  address,
+		facility: 1,
+		pid:      strconv.Itoa(os.Getpid()),
+		sdId:     defaultSyslogSdId,
+		sdFields: make(map[string]bool),
+		capacity: bufferSize,
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	switch network {
+	case "udp", "tcp", "unix", "unixgram":
+	case "tls", "tcp+tls":
+		a.network = "tcp"
+		tlsConfig, err := syslogTlsConfig(options, address)
+		if err != nil {
+			return nil, err
+		}
+		a.tlsConfig = tlsConfig
+	default:
+		return nil, fmt.Errorf("invalid network %q", network)
+	}
+	if facility := strings.ToLower(strings.TrimSpace(options[syslogAppenderOptionKeyFacility])); len(facility) > 0 {
+		code, exists := syslogFacilities[facility]
+		if !exists {
+			return nil, fmt.Errorf("invalid facility %q", facility)
+		}
+		a.facility = code
+	}
+	a.appName = strings.TrimSpace(options[syslogAppenderOptionKeyAppName])
+	if len(a.appName) == 0 {
+		a.appName = filepath.Base(os.Args[0])
+	}
+	a.hostname = strings.TrimSpace(options[syslogAppenderOptionKeyHostname])
+	if len(a.hostname) == 0 {
+		a.hostname, _ = os.Hostname()
+	}
+	switch rfc := strings.TrimSpace(options[syslogAppenderOptionKeyRfc]); rfc {
+	case "", "5424":
+	case "3164":
+		a.rfc3164 = true
+	default:
+		return nil, fmt.Errorf("invalid rfc %q", rfc)
+	}
+	switch framing := strings.ToLower(strings.TrimSpace(options[syslogAppenderOptionKeyFraming])); framing {
+	case "":
+		a.framing = syslogFramingNone
+		if a.network == "tcp" || a.network == "unix" {
+			a.framing = syslogFramingOctetCounting
+		}
+	case string(syslogFramingOctetCounting), string(syslogFramingNewline), string(syslogFramingNone):
+		a.framing = syslogFraming(framing)
+	default:
+		return nil, fmt.Errorf("invalid framing %q", framing)
+	}
+	if sdId := strings.TrimSpace(options[syslogAppenderOptionKeySdId]); len(sdId) > 0 {
+		a.sdId = sdId
+	}
+	for _, field := range splitList(options[syslogAppenderOptionKeyStructuredData]) {
+		if field == "*" {
+			a.sdAll = true
+		}
+		a.sdFields[field] = true
+	}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	a.cond = sync.NewCond(&a.lk)
+	go a.loop()
+	return a, nil
+}
+
+func syslogTlsConfig(options map[string]string, address string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	insecure, _ := strconv.ParseBool(options[syslogAppenderOptionKeyTlsInsecure])
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: insecure,
+	}
+	if caFile := strings.TrimSpace(options[syslogAppenderOptionKeyTlsCaFile]); len(caFile) > 0 {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// Write sends p at the informational severity, the level of p is unknown.
+func (a *syslogAppender) Write(p []byte) (int, error) {
+	return a.WriteEntry(&Entry{Level: LvlInfo, Time: time.Now()}, p)
+}
+
+// WriteEntry queues the entry, dropping the oldest one when the buffer is full.
+func (a *syslogAppender) WriteEntry(entry *Entry, p []byte) (int, error) {
+	msg := a.format(entry, bytes.TrimRight(p, "\n"))
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+	if len(a.queue) >= a.capacity {
+		a.queue = a.queue[1:]
+		a.dropped++
+	}
+	a.queue = append(a.queue, msg)
+	a.cond.Broadcast()
+	return len(p), nil
+}
+
+func (a *syslogAppender) loop() {
+	defer close(a.done)
+	for {
+		a.lk.Lock()
+		for len(a.queue) == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if len(a.queue) == 0 && a.closed {
+			a.lk.Unlock()
+			return
+		}
+		msg := a.queue[0]
+		a.queue = a.queue[1:]
+		a.sending = 1
+		closed := a.closed
+		a.lk.Unlock()
+
+		err := a.send(msg)
+
+		a.lk.Lock()
+		a.sending = 0
+		a.lastErr = err
+		if err != nil && !closed {
+			// sent again after the backoff, unless the buffer overflows meanwhile
+			a.queue = append([][]byte{msg}, a.queue...)
+			if len(a.queue) > a.capacity {
+				a.queue = a.queue[1:]
+				a.dropped++
+			}
+		} else if err != nil {
+			// closing, the server is unreachable or the flush deadline passed
+			a.dropped += uint64(1 + len(a.queue))
+			a.queue = nil
+		}
+		if err == nil || closed {
+			a.backoff = 0
+		} else if a.backoff < syslogMinBackoff {
+			a.backoff = syslogMinBackoff
+		} else if a.backoff < syslogMaxBackoff {
+			a.backoff *= 2
+		}
+		backoff := a.backoff
+		a.cond.Broadcast()
+		a.lk.Unlock()
+		if backoff > 0 {
+			a.wait(backoff)
+		}
+	}
+}
+
+// wait sleeps for the backoff unless the appender is closed meanwhile.
+func (a *syslogAppender) wait(backoff time.Duration) {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-a.closing:
+	}
+}
+
+// send writes msg on the connection, dialled when there is none, and once more on a new
+// connection when the server closed the previous one. Called by loop only.
+func (a *syslogAppender) send(msg []byte) error {
+	a.lk.Lock()
+	conn := a.conn
+	a.lk.Unlock()
+	reconnected := conn == nil
+	if reconnected {
+		var err error
+		if conn, err = a.connect(); err != nil {
+			return err
+		}
+	}
+	err := a.write(conn, msg)
+	if err != nil && !reconnected {
+		a.closeConn()
+		if conn, err = a.connect(); err == nil {
+			err = a.write(conn, msg)
+		}
+	}
+	if err != nil {
+		a.closeConn()
+	}
+	return err
+}
+
+func (a *syslogAppender) write(conn net.Conn, msg []byte) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	_, err := conn.Write(msg)
+	return err
+}
+
+func (a *syslogAppender) connect() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	var conn net.Conn
+	var err error
+	if a.tlsConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: a.tlsConfig}).DialContext(a.ctx, a.network, a.address)
+	} else {
+		conn, err = dialer.DialContext(a.ctx, a.network, a.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if a.ctx.Err() != nil {
+		// Close gave up on the entries meanwhile
+		_ = conn.Close()
+		return nil, a.ctx.Err()
+	}
+	a.conn = conn
+	return conn, nil
+}
+
+func (a *syslogAppender) closeConn() {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if a.conn != nil {
+		_ = a.conn.Close()
+		a.conn = nil
+	}
+}
+
+func (a *syslogAppender) format(entry *Entry, content []byte) []byte {
+	buf := &bytes.Buffer{}
+	pri := a.facility*8 + syslogSeverity(entry.Level)
+	timestamp := entry.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	if a.rfc3164 {
+		// <PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG
+		fmt.Fprintf(buf, "<%d>%s %s %s[%s]: ", pri, timestamp.Format(time.Stamp), a.hostname, a.appName, a.pid)
+	} else {
+		// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+		fmt.Fprintf(buf, "<%d>1 %s %s %s %s - ", pri, timestamp.Format("2006-01-02T15:04:05.000000Z07:00"),
+			syslogHeaderField(a.hostname, 255), syslogHeaderField(a.appName, 48), a.pid)
+		a.writeStructuredData(buf, entry.Fields)
+		buf.WriteByte(' ')
+	}
+	buf.Write(content)
+	switch a.framing {
+	case syslogFramingOctetCounting:
+		return append([]byte(strconv.Itoa(buf.Len())+" "), buf.Bytes()...)
+	case syslogFramingNewline:
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func (a *syslogAppender) writeStructuredData(buf *bytes.Buffer, fields []KeyVal) {
+	written := false
+	for _, kv := range fields {
+		if !a.sdAll && !a.sdFields[kv.Key] {
+			continue
+		}
+		name := syslogSdName(kv.Key)
+		if len(name) == 0 {
+			continue
+		}
+		if !written {
+			buf.WriteString("[" + a.sdId)
+			written = true
+		}
+		buf.WriteString(" " + name + "=\"")
+		syslogSdEscape(buf, fmt.Sprint(kv.Val))
+		buf.WriteByte('"')
+	}
+	if written {
+		buf.WriteByte(']')
+	} else {
+		buf.WriteByte('-')
+	}
+}
+
+// syslogHeaderField keeps the printable characters, "-" stands for an empty value.
+func syslogHeaderField(value string, max int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(field) == 0 {
+		return "-"
+	}
+	if len(field) > max {
+		return field[:max]
+	}
+	return field
+}
+
+// syslogSdName drops the characters an SD-NAME may not contain.
+func syslogSdName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' || r == ' ' {
+			return -1
+		}
+		return r
+	}, key)
+	if len(name) > 32 {
+		return name[:32]
+	}
+	return name
+}
+
+func syslogSdEscape(buf *bytes.Buffer, value string) {
+	for _, r := range value {
+		if r == '"' || r == '\\' || r == ']' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+}
+
+// Flush waits until the buffered entries are sent, or fails after syslogFlushTimeout.
+func (a *syslogAppender) Flush() error {
+	deadline := time.Now().Add(syslogFlushTimeout)
+	for {
+		a.lk.Lock()
+		pending := len(a.queue) + a.sending
+		lastErr := a.lastErr
+		a.lk.Unlock()
+		if pending == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("syslog appender: %d entries not sent: %v", pending, lastErr)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Close sends the buffered entries within syslogFlushTimeout overall, the entries left
+// when the deadline passes or a write fails are dropped.
+func (a *syslogAppender) Close() error {
+	a.lk.Lock()
+	if a.closed {
+		a.lk.Unlock()
+		return nil
+	}
+	a.closed = true
+	dropped := a.dropped
+	close(a.closing)
+	a.cond.Broadcast()
+	a.lk.Unlock()
+	deadline := time.AfterFunc(syslogFlushTimeout, a.abort)
+	<-a.done
+	deadline.Stop()
+	a.abort()
+	a.lk.Lock()
+	dropped = a.dropped - dropped
+	lastErr := a.lastErr
+	a.lk.Unlock()
+	if dropped > 0 {
+		return fmt.Errorf("syslog appender: %d entries not sent: %v", dropped, lastErr)
+	}
+	return nil
+}
+
+// abort cancels the dial and closes the connection, failing the write in flight.
+func (a *syslogAppender) abort() {
+	a.cancel()
+	a.closeConn()
+}
+
+func (a *syslogAppender) Name() string {
+	return "syslog:" + a.network + "://" + a.address
+}
+
+// Health reports the error of the last write.
+func (a *syslogAppender) Health() error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.lastErr
+}
+
+// Dropped returns the number of entries dropped because the buffer was full or the server
+// was unreachable at Close.
+func (a *syslogAppender) Dropped() uint64 {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.dropped
+}
@@ -0,0 +1,201 @@
+package factory
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSyslogAppender(t *testing.T, options map[string]string) *syslogAppender {
+	t.Helper()
+	options["app-name"] = "app"
+	options["hostname"] = "host"
+	a, err := newSyslogAppender(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = a.Close() })
+	return a.(*syslogAppender)
+}
+
+func testSyslogEntry(level LevelNum) *Entry {
+	return &Entry{
+		Logger:  "a/b",
+		Level:   level,
+		Time:    time.Date(2024, 3, 5, 7, 8, 9, 123456000, time.UTC),
+		Message: "hello",
+		Fields:  []KeyVal{{Key: "user", Val: `x"y`}, {Key: "other", Val: 1}},
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	for level, severity := range map[LevelNum]int{
+		LvlTrace - 1: 7, LvlTrace: 7, LvlDebug: 7, LvlInfo: 6, LvlWarn: 4,
+		LvlError: 3, LvlDPanic: 2, LvlPanic: 1, LvlFatal: 0, LvlFatal + 1: 0,
+	} {
+		if s := syslogSeverity(level); s != severity {
+			t.Errorf("level %d severity %d, want %d", level, s, severity)
+		}
+	}
+}
+
+func TestSyslogUdp5424(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	a := testSyslogAppender(t, map[string]string{
+		"network": "udp", "address": conn.LocalAddr().String(), "facility": "local0", "structured-data": "user",
+	})
+	if _, err := a.WriteEntry(testSyslogEntry(LvlWarn), []byte("the message\n")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2048)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local0 is 16, warning 4
+	want := `<132>1 2024-03-05T07:08:09.123456Z host app ` + a.pid + ` - [lf4go@32473 user="x\"y"] the message`
+	if got := string(buf[:n]); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestSyslogTcp3164(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			// octet counting: MSG-LEN SP SYSLOG-MSG
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+	a := testSyslogAppender(t, map[string]string{"network": "tcp", "address": listener.Addr().String(), "rfc": "3164"})
+	for _, level := range []LevelNum{LvlError, LvlDebug} {
+		if _, err := a.WriteEntry(testSyslogEntry(level), []byte("the message")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// user is 1: error 8+3, debug 8+7
+	for _, pri := range []string{"<11>", "<15>"} {
+		select {
+		case msg := <-received:
+			pattern := regexp.MustCompile(`^` + pri + `Mar  5 07:08:09 host app\[\d+\]: the message$`)
+			if !pattern.MatchString(msg) {
+				t.Errorf("message %q does not match %s", msg, pattern)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no message received")
+		}
+	}
+}
+
+func TestSyslogBuffersWhileDisconnected(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+	a := testSyslogAppender(t, map[string]string{"network": "tcp", "address": address, "buffer-size": "2"})
+
+	// the writes never wait for the server, the oldest entry is dropped once the buffer is full
+	for _, p := range []string{"1", "2", "3"} {
+		if _, err := a.WriteEntry(testSyslogEntry(LvlInfo), []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for deadline := time.Now().Add(5 * time.Second); a.Health() == nil; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("dial error not reported")
+		}
+	}
+	if a.Dropped() != 1 {
+		t.Errorf("dropped %d, want 1", a.Dropped())
+	}
+
+	// the reconnect after the backoff sends the buffered entries
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Skip("port taken meanwhile: ", err)
+	}
+	defer listener.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+	for _, want := range []string{"2", "3"} {
+		select {
+		case msg := <-received:
+			if !strings.HasSuffix(msg, " - "+want) {
+				t.Errorf("message %q, want %s", msg, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("buffered entries not sent")
+		}
+	}
+	if err := a.Flush(); err != nil || a.Health() != nil {
+		t.Errorf("flush %v health %v after reconnect", err, a.Health())
+	}
+}
+
+func TestSyslogCloseDropsWhileDisconnected(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+	a := testSyslogAppender(t, map[string]string{"network": "tcp", "address": address})
+	_, _ = a.WriteEntry(testSyslogEntry(LvlInfo), []byte("1"))
+	if err := a.Close(); err == nil || a.Dropped() != 1 {
+		t.Errorf("close %v dropped %d", err, a.Dropped())
+	}
+	if _, err := a.WriteEntry(testSyslogEntry(LvlInfo), []byte("2")); err == nil {
+		t.Error("write after close succeeded")
+	}
+}
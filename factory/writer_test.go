@@ -0,0 +1,198 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingAppender fails every write.
+type failingAppender struct{}
+
+func (a failingAppender) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func (a failingAppender) Flush() error {
+	return nil
+}
+
+func (a failingAppender) Close() error {
+	return nil
+}
+
+func (a failingAppender) Name() string {
+	return "failing"
+}
+
+func TestRegisterAppender(t *testing.T) {
+	created := 0
+	RegisterAppender("Failing", func(options map[string]string) (Appender, error) {
+		created++
+		if "" == options["reason"] {
+			return nil, errors.New("reason is required")
+		}
+		return failingAppender{}, nil
+	})
+	config, out := testMemoryConfig(t, "normal")
+	config.Appenders = append(config.Appenders, AppenderConfig{Type: "failing", Options: map[string]string{"reason": fmt.Sprint("test-", memorySeq.Add(1))}})
+	f := testFactory(t, "zap")
+	l := f.NewPackageLogger("appender/one", config)
+	f.NewPackageLogger("appender/two", config)
+	l.Info("written")
+	if lines := out.lines(); len(lines) != 1 || !strings.Contains(lines[0], "written") {
+		t.Errorf("an appender failing stopped the others: %q", lines)
+	}
+	if created != 1 {
+		t.Errorf("created %d appenders, want 1 shared", created)
+	}
+
+	invalid := *config
+	invalid.Appenders = []AppenderConfig{{Type: "failing"}}
+	if err := f.Reload(&invalid); err == nil || !strings.Contains(err.Error(), "reason is required") {
+		t.Errorf("reload error %v", err)
+	}
+}
+
+func TestUnknownAppender(t *testing.T) {
+	config, _ := testMemoryConfig(t, "normal")
+	f := testFactory(t, "zap")
+	f.NewPackageLogger("appender/unknown", config)
+	invalid := *config
+	invalid.Appenders = []AppenderConfig{{Type: "memory", Options: config.Appenders[0].Options}, {Type: "mongodb"}}
+	if err := f.Reload(&invalid); err == nil || !strings.Contains(err.Error(), `unknown appender type "mongodb"`) {
+		t.Errorf("reload error %v", err)
+	}
+	if _, err := testFactory(t, "zap").NewPackageLoggerE("appender/unknown", &invalid); err == nil ||
+		!strings.Contains(err.Error(), `logger appender/unknown: unknown appender type "mongodb"`) {
+		t.Errorf("logger error %v", err)
+	}
+}
+
+func TestInvalidAppenderLevel(t *testing.T) {
+	config, _ := testMemoryConfig(t, "normal")
+	f := testFactory(t, "zap")
+	f.NewPackageLogger("appender/invalid", config)
+	memory := config.Appenders[0]
+	level := memory
+	level.Level = "loud"
+	overflow := AppenderConfig{Type: "async", Options: map[string]string{"overflow-level": "loud"}, Appenders: []AppenderConfig{memory}}
+	for _, appender := range []AppenderConfig{level, overflow} {
+		invalid := *config
+		invalid.Appenders = []AppenderConfig{appender}
+		if err := f.Reload(&invalid); err == nil || !strings.Contains(err.Error(), `level "loud"`) {
+			t.Errorf("%s: reload error %v", appender.Type, err)
+		}
+		if _, err := testFactory(t, "zap").NewPackageLoggerE("appender/invalid", &invalid); err == nil {
+			t.Errorf("%s: logger created", appender.Type)
+		}
+	}
+}
+
+func TestAppenderFormatterAndLevel(t *testing.T) {
+	for _, impl := range testBackends {
+		config, all := testMemoryConfig(t, "normal")
+		jsonConfig, infos := testMemoryConfig(t, "normal")
+		patternConfig, warns := testMemoryConfig(t, "normal")
+		jsonAppender := jsonConfig.Appenders[0]
+		jsonAppender.Formatter = "json"
+		jsonAppender.Level = "info"
+		patternAppender := patternConfig.Appenders[0]
+		patternAppender.Formatter = "pattern"
+		patternAppender.Pattern = "%p %m%n"
+		patternAppender.Level = "WARN"
+		config.RootLevel = "debug"
+		config.Appenders = append(config.Appenders, jsonAppender, patternAppender)
+
+		l := testFactory(t, impl).NewPackageLogger("appender/level/"+impl, config)
+		l.Debug("debug")
+		l.Infow("info", "k", 1)
+		l.Error("error")
+
+		if lines := all.lines(); len(lines) != 3 || strings.HasPrefix(lines[0], "{") {
+			t.Errorf("%s: normal lines %q", impl, lines)
+		}
+		entries := jsonLines(t, infos.lines())
+		if len(entries) != 2 || jsonMessage(entries[0]) != "info" || entries[0]["k"] != float64(1) || jsonMessage(entries[1]) != "error" {
+			t.Errorf("%s: json entries %v", impl, entries)
+		}
+		if lines := warns.lines(); strings.Join(lines, "\n") != "ERROR error" {
+			t.Errorf("%s: pattern lines %q", impl, lines)
+		}
+	}
+}
+
+// blockingEntryAppender holds the entries with the message "blocked" until release is closed.
+type blockingEntryAppender struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (a *blockingEntryAppender) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (a *blockingEntryAppender) WriteEntry(entry *Entry, p []byte) (int, error) {
+	if "blocked" == entry.Message {
+		a.entered <- struct{}{}
+		<-a.release
+	}
+	return len(p), nil
+}
+
+func (a *blockingEntryAppender) Flush() error {
+	return nil
+}
+
+func (a *blockingEntryAppender) Close() error {
+	return nil
+}
+
+func (a *blockingEntryAppender) Name() string {
+	return "blocking"
+}
+
+var blockingEntryAppenders = &sync.Map{}
+
+// an appender slow to take an entry does not hold the other calls of the logger
+func TestEntriesOfALoggerAreNotSerialized(t *testing.T) {
+	RegisterAppender("blocking", func(options map[string]string) (Appender, error) {
+		a, _ := blockingEntryAppenders.Load(options["id"])
+		return a.(*blockingEntryAppender), nil
+	})
+	for _, impl := range testBackends {
+		appender := &blockingEntryAppender{entered: make(chan struct{}, 1), release: make(chan struct{})}
+		id := fmt.Sprint("blocking-", memorySeq.Add(1))
+		blockingEntryAppenders.Store(id, appender)
+		config, out := testMemoryConfig(t, "normal")
+		config.Appenders = append(config.Appenders, AppenderConfig{Type: "blocking", Options: map[string]string{"id": id}})
+		l := testFactory(t, impl).NewPackageLogger("appender/blocking/"+impl, config)
+
+		blocked := make(chan struct{})
+		go func() {
+			l.Info("blocked")
+			close(blocked)
+		}()
+		<-appender.entered
+		free := make(chan struct{})
+		go func() {
+			l.Info("free")
+			close(free)
+		}()
+		select {
+		case <-free:
+			break
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: the entry waits for the blocked one", impl)
+		}
+		close(appender.release)
+		<-blocked
+		<-free
+		if rows := strings.Join(out.lines(), "\n"); !strings.Contains(rows, "blocked") || !strings.Contains(rows, "free") {
+			t.Errorf("%s: rows %s", impl, rows)
+		}
+	}
+}
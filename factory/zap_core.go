@@ -0,0 +1,173 @@
+package factory
+
+import (
+	"go.uber.org/zap/zapcore"
+	"runtime"
+)
+
+// zapEntryCore is the core of zapcore.NewCore, describing every entry to the EntryAppenders of out.
+type zapEntryCore struct {
+	zapcore.LevelEnabler
+	name   string
+	enc    zapcore.Encoder
+	out    *mergedWriter
+	fields []zapcore.Field // added by With, kept for the entries
+}
+
+func newZapEntryCore(name string, enc zapcore.Encoder, out *mergedWriter, enab zapcore.LevelEnabler) zapcore.Core {
+	return &zapEntryCore{
+		LevelEnabler: enab,
+		name:         name,
+		enc:          enc,
+		out:          out,
+	}
+}
+
+func (c *zapEntryCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *zapEntryCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *zapEntryCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	entry := &Entry{
+		Logger:  c.name,
+		Level:   zapLevelNum(ent.Level),
+		Time:    ent.Time,
+		Message: ent.Message,
+		Fields:  zapKeyVals(c.fields, fields),
+		Caller:  zapCallerFrame(ent.Caller),
+	}
+	_, err = c.out.WriteEntry(entry, buf.Bytes())
+	buf.Free()
+	if err != nil {
+		return err
+	}
+	if ent.Level > zapcore.ErrorLevel {
+		// the process may be about to exit
+		_ = c.Sync()
+	}
+	return nil
+}
+
+func (c *zapEntryCore) Sync() error {
+	return c.out.Sync()
+}
+
+func zapKeyVals(bound []zapcore.Field, fields []zapcore.Field) []KeyVal {
+	if len(bound)+len(fields) == 0 {
+		return nil
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	kvs := make([]KeyVal, 0, len(bound)+len(fields))
+	for _, f := range bound {
+		f.AddTo(enc)
+		kvs = append(kvs, KeyVal{Key: f.Key, Val: enc.Fields[f.Key]})
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+		kvs = append(kvs, KeyVal{Key: f.Key, Val: enc.Fields[f.Key]})
+	}
+	return kvs
+}
+
+// zapTraceLevel is the TRACE of lf4go, zap has no level below DEBUG.
+const zapTraceLevel = zapcore.DebugLevel - 1
+
+// zapLevelEncoder renders zapTraceLevel as trace, the zap levels like zapcore.LowercaseLevelEncoder.
+func zapLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if level == zapTraceLevel {
+		enc.AppendString("trace")
+		return
+	}
+	zapcore.LowercaseLevelEncoder(level, enc)
+}
+
+// zapLevel
+func zapLevel(level LevelNum) zapcore.Level {
+	switch level {
+	case LvlTrace:
+		return zapTraceLevel
+	case LvlDebug:
+		return zapcore.DebugLevel
+	case LvlInfo:
+		return zapcore.InfoLevel
+	case LvlWarn:
+		return zapcore.WarnLevel
+	case LvlError:
+		return zapcore.ErrorLevel
+	case LvlDPanic:
+		return zapcore.DPanicLevel
+	case LvlPanic:
+		return zapcore.PanicLevel
+	case LvlFatal:
+		return zapcore.FatalLevel
+	}
+	return zapcore.InfoLevel
+}
+
+// zapEntryCaller is the zap caller of a pc of callerPC.
+func zapEntryCaller(pc uintptr) zapcore.EntryCaller {
+	frame := callerFrame(pc)
+	if frame == nil {
+		return zapcore.EntryCaller{}
+	}
+	return zapcore.EntryCaller{
+		Defined:  true,
+		PC:       frame.PC,
+		File:     frame.File,
+		Line:     frame.Line,
+		Function: frame.Function,
+	}
+}
+
+func zapCallerFrame(caller zapcore.EntryCaller) *runtime.Frame {
+	if !caller.Defined {
+		return nil
+	}
+	return &runtime.Frame{
+		PC:       caller.PC,
+		File:     caller.File,
+		Line:     caller.Line,
+		Function: caller.Function,
+	}
+}
+
+func zapLevelNum(level zapcore.Level) LevelNum {
+	switch level {
+	case zapTraceLevel:
+		return LvlTrace
+	case zapcore.DebugLevel:
+		return LvlDebug
+	case zapcore.InfoLevel:
+		return LvlInfo
+	case zapcore.WarnLevel:
+		return LvlWarn
+	case zapcore.ErrorLevel:
+		return LvlError
+	case zapcore.DPanicLevel:
+		return LvlDPanic
+	case zapcore.PanicLevel:
+		return LvlPanic
+	case zapcore.FatalLevel:
+		return LvlFatal
+	}
+	return LvlInfo
+}
@@ -0,0 +1,61 @@
+package factory
+
+import (
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"sort"
+)
+
+var zapPatternBuffers = buffer.NewPool()
+
+// zapPatternEncoder renders the entries with a patternLayout, the fields added by With
+// are kept by the embedded MapObjectEncoder.
+type zapPatternEncoder struct {
+	*zapcore.MapObjectEncoder
+	name   string
+	layout *patternLayout
+}
+
+func newZapPatternEncoder(name string, pattern string) zapcore.Encoder {
+	return &zapPatternEncoder{
+		MapObjectEncoder: zapcore.NewMapObjectEncoder(),
+		name:             name,
+		layout:           mustPatternLayout(pattern),
+	}
+}
+
+func (e *zapPatternEncoder) Clone() zapcore.Encoder {
+	clone := zapcore.NewMapObjectEncoder()
+	for k, v := range e.Fields {
+		clone.Fields[k] = v
+	}
+	return &zapPatternEncoder{
+		MapObjectEncoder: clone,
+		name:             e.name,
+		layout:           e.layout,
+	}
+}
+
+func (e *zapPatternEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	kvs := make([]KeyVal, 0, len(e.Fields)+len(fields))
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kvs = append(kvs, KeyVal{Key: k, Val: e.Fields[k]})
+	}
+	kvs = append(kvs, zapKeyVals(nil, fields)...)
+	entry := &Entry{
+		Logger:  e.name,
+		Level:   zapLevelNum(ent.Level),
+		Time:    ent.Time,
+		Message: ent.Message,
+		Fields:  kvs,
+		Caller:  zapCallerFrame(ent.Caller),
+	}
+	buf := zapPatternBuffers.Get()
+	_, _ = buf.Write(e.layout.format(entry))
+	return buf, nil
+}
@@ -9,100 +9,46 @@ import (
 type ZapLoggerFactory string
 
 func (zf *ZapLoggerFactory) NewFactory(callerPackageDetector func(caller string) string) *LoggerFactory {
-	internalFactory := ZapLoggerFactoryImpl
-	factory := &LoggerFactory{
-		callerPackage: callerPackageDetector,
-		delegate:      &internalFactory,
-	}
-	return factory
+	backend := ZapLoggerFactoryImpl
+	return newLoggerFactory(callerPackageDetector, &backend)
 }
 
-func (zf *ZapLoggerFactory) getLevels(prefix string) map[string]string {
-	levels := make(map[string]string, 16)
-	if "ROOT" == strings.ToUpper(prefix) {
-		for k, logger := range loggers {
-			levels[k] = logLevelName(logger.Config.Level)
-		}
-	} else {
-		for k, logger := range loggers {
-			if strings.HasPrefix(k, prefix) {
-				levels[k] = logLevelName(logger.Config.Level)
-			}
-		}
+// SetLevel flips the zap.AtomicLevel shared by the cores of the logger and its children, the sinks are kept.
+func (zf *ZapLoggerFactory) SetLevel(delegate LoggerDelegate, loggerConfig *LoggerConfig) LoggerDelegate {
+	logger, ok := delegate.(*ZapLogger)
+	if !ok {
+		return zf.NewDelegate(loggerConfig)
 	}
-	return levels
+	level, _ := zf.logLevel(logLevelName(loggerConfig.Level))
+	logger.config.Level.SetLevel(level.Level())
+	return logger
 }
 
-func (zf *ZapLoggerFactory) setLevels(prefix string, level string) {
-	if "ROOT" == strings.ToUpper(prefix) {
-		for k, logger := range loggers {
-			var levelObj zap.AtomicLevel
-			var levelNum LevelNum
-			levelObj, levelNum = zf.logLevel(level)
-			sink, conf := zf.setLevel(k, levelObj)
-			delegate := &ZapLogger{
-				config:  conf,
-				sink:    sink,
-				factory: zf,
-			}
-			logger.Config.Level = levelNum
-			logger.delegate = delegate
-		}
-		return
-	}
-	for k, logger := range loggers {
-		if strings.HasPrefix(k, prefix) {
-			var levelObj zap.AtomicLevel
-			var levelNum LevelNum
-			levelObj, levelNum = zf.logLevel(level)
-			sink, conf := zf.setLevel(k, levelObj)
-			delegate := &ZapLogger{
-				config:  conf,
-				sink:    sink,
-				factory: zf,
-			}
-			logger.Config.Level = levelNum
-			logger.delegate = delegate
-		}
-	}
-}
-
-func (zf *ZapLoggerFactory) setLevel(name string, level zap.AtomicLevel) (*zap.Logger, *zap.Config) {
-	logger := loggers[name]
-	loggerConfig := logger.Config
-	internal := logger.delegate.(*ZapLogger)
-	internal.config.Level = level
-	return newZapLogger(loggerConfig, internal.config), internal.config
-}
-
-// newLogger
+// NewDelegate
 // []string{"stdout"},
 // []string{"stderr"},
-func (zf *ZapLoggerFactory) newLogger(loggerConfig *LoggerConfig) *Logger {
+func (zf *ZapLoggerFactory) NewDelegate(loggerConfig *LoggerConfig) LoggerDelegate {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout(DTFormatNormal)
+	encoderConfig.EncodeLevel = zapLevelEncoder
+	if loggerConfig.ReportCaller {
+		// the caller is set by ZapLogger.LogCaller
+		encoderConfig.FunctionKey = "function"
+	}
 	atomicLevel, _ := zf.logLevel(logLevelName(loggerConfig.Level))
 	encoding := zf.formatterToEncoding(loggerConfig.Formatter)
 	config := &zap.Config{
-		Level:       atomicLevel,
-		Development: false,
-		Sampling: &zap.SamplingConfig{
-			Initial:    100,
-			Thereafter: 100,
-		},
+		Level:         atomicLevel,
+		Development:   false,
 		Encoding:      encoding,
 		EncoderConfig: encoderConfig,
 	}
 	sink := newZapLogger(loggerConfig, config)
-	delegate := &ZapLogger{
+	return &ZapLogger{
 		config:  config,
 		sink:    sink,
 		factory: zf,
 	}
-	return &Logger{
-		Config:   loggerConfig,
-		delegate: delegate,
-	}
 }
 
 func (zf *ZapLoggerFactory) formatterToEncoding(formatter string) string {
@@ -111,6 +57,8 @@ func (zf *ZapLoggerFactory) formatterToEncoding(formatter string) string {
 		encoding = "console"
 	} else if encoding == "json" {
 		encoding = "json"
+	} else if encoding == "pattern" {
+		encoding = "pattern"
 	} else {
 		encoding = "console"
 	}
@@ -120,23 +68,35 @@ func (zf *ZapLoggerFactory) formatterToEncoding(formatter string) string {
 type zapEncodingName string
 
 const (
-	zapEncodingNormal zapEncodingName = "console"
-	zapEncodingJson   zapEncodingName = "json"
+	zapEncodingNormal  zapEncodingName = "console"
+	zapEncodingJson    zapEncodingName = "json"
+	zapEncodingPattern zapEncodingName = "pattern"
 )
 
 // newZapLogger
 // []string{"stdout"},
 // []string{"stderr"},
 func newZapLogger(loggerConfig *LoggerConfig, config *zap.Config) *zap.Logger {
-	var encoder zapcore.Encoder
-	if string(zapEncodingNormal) == config.Encoding {
-		encoder = zapcore.NewConsoleEncoder(config.EncoderConfig)
-	} else if string(zapEncodingJson) == config.Encoding {
-		encoder = zapcore.NewJSONEncoder(config.EncoderConfig)
+	cores := make([]zapcore.Core, 0, len(loggerConfig.Outputs))
+	for _, output := range loggerConfig.Outputs {
+		encoding := ZapLoggerFactoryImpl.formatterToEncoding(output.Formatter)
+		var encoder zapcore.Encoder
+		if string(zapEncodingNormal) == encoding {
+			encoder = zapcore.NewConsoleEncoder(config.EncoderConfig)
+		} else if string(zapEncodingJson) == encoding {
+			encoder = zapcore.NewJSONEncoder(config.EncoderConfig)
+		} else if string(zapEncodingPattern) == encoding {
+			encoder = newZapPatternEncoder(loggerConfig.Name, output.Pattern)
+		}
+		threshold, _ := ZapLoggerFactoryImpl.logLevel(logLevelName(output.Level))
+		enabler := zapOutputLevel{level: config.Level, threshold: threshold.Level()}
+		if merged, ok := output.Writer.(*mergedWriter); ok && merged.needsEntry {
+			cores = append(cores, newZapEntryCore(loggerConfig.Name, encoder, merged, enabler))
+		} else {
+			cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(output.Writer), enabler))
+		}
 	}
-	log := zap.New(
-		zapcore.NewCore(encoder, zapcore.AddSync(writer(loggerConfig.Name, loggerConfig.Appenders)), config.Level),
-	)
+	log := zap.New(zapcore.NewTee(cores...))
 	//delegate := log.WithOptions(
 	//	zap.AddCallerSkip(3),
 	//	zap.AddCaller(),
@@ -146,12 +106,22 @@ func newZapLogger(loggerConfig *LoggerConfig, config *zap.Config) *zap.Logger {
 	return delegate
 }
 
+// zapOutputLevel enables the levels enabled by the logger and above the threshold of an output.
+type zapOutputLevel struct {
+	level     zap.AtomicLevel
+	threshold zapcore.Level
+}
+
+func (l zapOutputLevel) Enabled(level zapcore.Level) bool {
+	return level >= l.threshold && l.level.Enabled(level)
+}
+
 func (zf *ZapLoggerFactory) logLevel(level string) (zap.AtomicLevel, LevelNum) {
 	var levelObj = zap.NewAtomicLevelAt(zapcore.InfoLevel)
 	var levelNum = LvlInfo
 	switch strings.ToUpper(level) {
 	case "TRACE":
-		levelObj = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+		levelObj = zap.NewAtomicLevelAt(zapTraceLevel)
 		levelNum = LvlTrace
 		break
 	case "DEBUG":
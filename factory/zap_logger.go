@@ -2,7 +2,6 @@ package factory
 
 import (
 	"go.uber.org/zap"
-	"reflect"
 )
 
 type ZapLogger struct {
@@ -11,64 +10,58 @@ type ZapLogger struct {
 	factory *ZapLoggerFactory
 }
 
-func (l *ZapLogger) Trace(msg string) {
-	//l.log(msg, l.sink.Debug)
-	l.sink.Debug(msg)
+func (l *ZapLogger) Trace(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlTrace, 0, msg, kvs...)
 }
-func (l *ZapLogger) Debug(msg string) {
-	//l.log(msg, l.sink.Debug)
-	l.sink.Debug(msg)
+func (l *ZapLogger) Debug(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlDebug, 0, msg, kvs...)
 }
-func (l *ZapLogger) Info(msg string) {
-	//l.log(msg, l.sink.Info)
-	l.sink.Info(msg)
+func (l *ZapLogger) Info(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlInfo, 0, msg, kvs...)
 }
-func (l *ZapLogger) Warn(msg string) {
-	// l.log(msg, l.sink.Warn)
-	l.sink.Warn(msg)
+func (l *ZapLogger) Warn(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlWarn, 0, msg, kvs...)
 }
-func (l *ZapLogger) Error(msg string) {
-	//l.log(msg, l.sink.Error)
-	l.sink.Error(msg)
+func (l *ZapLogger) Error(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlError, 0, msg, kvs...)
 }
-func (l *ZapLogger) Fatal(msg string) {
-	//l.log(msg, l.sink.Fatal)
-	l.sink.Fatal(msg)
+func (l *ZapLogger) Fatal(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlFatal, 0, msg, kvs...)
 }
-func (l *ZapLogger) DPanic(msg string) {
-	//l.log(msg, l.sink.DPanic)
-	l.sink.DPanic(msg)
+func (l *ZapLogger) DPanic(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlDPanic, 0, msg, kvs...)
 }
-func (l *ZapLogger) Panic(msg string) {
-	//l.log(msg, l.sink.Panic)
-	l.sink.Panic(msg)
+func (l *ZapLogger) Panic(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlPanic, 0, msg, kvs...)
 }
 
-func (l *ZapLogger) log(msg string, f func(string, ...zap.Field), kvs ...interface{}) {
-	if kvs == nil || len(kvs) == 0 {
-		f(msg)
+// LogCaller sets the caller of the checked entry, zap panics and exits on the panic and fatal levels.
+func (l *ZapLogger) LogCaller(level LevelNum, pc uintptr, msg string, kvs ...KeyVal) {
+	ce := l.sink.Check(zapLevel(level), msg)
+	if ce == nil {
 		return
 	}
-	fields := l.convert(kvs)
-	if fields == nil {
-		f(msg)
-		return
+	if pc != 0 {
+		ce.Caller = zapEntryCaller(pc)
+	}
+	ce.Write(l.convert(kvs)...)
+}
+
+func (l *ZapLogger) With(kvs []KeyVal) LoggerDelegate {
+	return &ZapLogger{
+		config:  l.config,
+		sink:    l.sink.With(l.convert(kvs)...),
+		factory: l.factory,
 	}
-	f(msg, fields...)
 }
 
-func (l *ZapLogger) convert(elements ...interface{}) []zap.Field {
-	if elements == nil || len(elements) == 0 {
+func (l *ZapLogger) convert(kvs []KeyVal) []zap.Field {
+	if len(kvs) == 0 {
 		return nil
 	}
-	fields := make([]zap.Field, len(elements))
-	for i, o := range elements {
-		if reflect.TypeOf(o).Name() == "KeyVal" {
-			kv := o.(KeyVal)
-			fields[i] = zap.Any(kv.Key, kv.Val)
-		} else {
-			fields[i] = zap.Any("", o)
-		}
+	fields := make([]zap.Field, len(kvs))
+	for i, kv := range kvs {
+		fields[i] = zap.Any(kv.Key, kv.Val)
 	}
 	return fields
 }
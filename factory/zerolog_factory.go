@@ -0,0 +1,138 @@
+package factory
+
+import (
+	"github.com/rs/zerolog"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type ZerologLoggerFactory string
+
+func (zf *ZerologLoggerFactory) NewFactory(callerPackageDetector func(caller string) string) *LoggerFactory {
+	backend := ZerologLoggerFactoryImpl
+	return newLoggerFactory(callerPackageDetector, &backend)
+}
+
+// SetLevel flips the level shared by the logger and its children, the writers are kept.
+func (zf *ZerologLoggerFactory) SetLevel(delegate LoggerDelegate, loggerConfig *LoggerConfig) LoggerDelegate {
+	logger, ok := delegate.(*ZerologLogger)
+	if !ok {
+		return zf.NewDelegate(loggerConfig)
+	}
+	zerologLevel, _ := zf.logLevel(logLevelName(loggerConfig.Level))
+	logger.level.Store(int32(zerologLevel))
+	return logger
+}
+
+// NewDelegate
+// []string{"stdout", "logs/application.log"},
+func (zf *ZerologLoggerFactory) NewDelegate(loggerConfig *LoggerConfig) LoggerDelegate {
+	zerologLevel, _ := zf.logLevel(logLevelName(loggerConfig.Level))
+	level := &atomic.Int32{}
+	level.Store(int32(zerologLevel))
+	logger := &ZerologLogger{
+		name:    loggerConfig.Name,
+		level:   level,
+		sink:    zerolog.New(zf.newWriters(loggerConfig, nil)).Hook(zerologTimestampHook{}),
+		factory: zf,
+	}
+	if needsEntries(loggerConfig.Outputs) {
+		logger.entries = loggerConfig
+	}
+	return logger
+}
+
+// newWriters returns the writers of the outputs, those of a logging call when bridge is set.
+func (zf *ZerologLoggerFactory) newWriters(loggerConfig *LoggerConfig, bridge *entryBridge) zerolog.LevelWriter {
+	writers := make([]io.Writer, 0, len(loggerConfig.Outputs))
+	for _, output := range loggerConfig.Outputs {
+		writers = append(writers, zf.newWriter(loggerConfig.Name, output, bridge))
+	}
+	return zerolog.MultiLevelWriter(writers...)
+}
+
+func (zf *ZerologLoggerFactory) newWriter(name string, output LoggerOutput, bridge *entryBridge) io.Writer {
+	out := bridge.writer(output.Writer)
+	if "pattern" == strings.ToLower(output.Formatter) {
+		out = newZerologPatternWriter(name, output.Pattern, out)
+	} else if "json" != strings.ToLower(output.Formatter) {
+		out = zerolog.ConsoleWriter{
+			Out:        out,
+			NoColor:    true,
+			TimeFormat: DTFormatNormal,
+		}
+	}
+	return &zerologOutputWriter{threshold: output.Level, out: out}
+}
+
+// zerologTimestampHook adds the time formatted with DTFormatNormal, unlike With().Timestamp()
+// it leaves the package level zerolog.TimeFieldFormat of the other zerolog users alone.
+type zerologTimestampHook struct{}
+
+// Run appends the quoted time to the event without allocating, DTFormatNormal needs no escaping.
+func (h zerologTimestampHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	var buf [64]byte
+	ts := append(buf[:0], '"')
+	ts = time.Now().AppendFormat(ts, DTFormatNormal)
+	ts = append(ts, '"')
+	e.RawJSON(zerolog.TimestampFieldName, ts)
+}
+
+// zerologOutputWriter drops the entries below the level of an output.
+type zerologOutputWriter struct {
+	threshold LevelNum
+	out       io.Writer
+}
+
+func (w *zerologOutputWriter) Write(p []byte) (int, error) {
+	return w.out.Write(p)
+}
+
+func (w *zerologOutputWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if zerologLevelNum(level) < w.threshold {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+func (zf *ZerologLoggerFactory) logLevel(level string) (zerolog.Level, LevelNum) {
+	var zerologLevel = zerolog.InfoLevel
+	var levelNum = LvlInfo
+	switch strings.ToUpper(level) {
+	case "TRACE":
+		zerologLevel = zerolog.TraceLevel
+		levelNum = LvlTrace
+		break
+	case "DEBUG":
+		zerologLevel = zerolog.DebugLevel
+		levelNum = LvlDebug
+		break
+	case "INFO":
+		zerologLevel = zerolog.InfoLevel
+		levelNum = LvlInfo
+		break
+	case "WARN":
+		zerologLevel = zerolog.WarnLevel
+		levelNum = LvlWarn
+		break
+	case "ERROR":
+		zerologLevel = zerolog.ErrorLevel
+		levelNum = LvlError
+		break
+	case "DPANIC":
+		zerologLevel = zerolog.PanicLevel
+		levelNum = LvlDPanic
+		break
+	case "PANIC":
+		zerologLevel = zerolog.PanicLevel
+		levelNum = LvlPanic
+		break
+	case "FATAL":
+		zerologLevel = zerolog.FatalLevel
+		levelNum = LvlFatal
+		break
+	}
+	return zerologLevel, levelNum
+}
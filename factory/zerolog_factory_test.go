@@ -0,0 +1,59 @@
+package factory
+
+import (
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var normalTime = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3}`)
+
+func TestZerologKeepsTimeFieldFormat(t *testing.T) {
+	before := zerolog.TimeFieldFormat
+	config, out := testMemoryConfig(t, "json")
+	l := testFactory(t, "zerolog").NewPackageLogger("zerolog/time", config)
+	l.Infow("hello", "at", time.Date(2024, 3, 5, 7, 8, 9, 0, time.UTC))
+	if zerolog.TimeFieldFormat != before {
+		t.Errorf("zerolog.TimeFieldFormat changed to %q", zerolog.TimeFieldFormat)
+	}
+	lines := out.lines()
+	if len(lines) != 1 {
+		t.Fatalf("lines %q", lines)
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(lines[0]), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if ts, _ := fields["time"].(string); !normalTime.MatchString(ts) {
+		t.Errorf("time %q not formatted with DTFormatNormal", fields["time"])
+	}
+	if at, _ := fields["at"].(string); at != "2024-03-05T07:08:09Z" {
+		t.Errorf("time field %q not formatted with zerolog.TimeFieldFormat", fields["at"])
+	}
+}
+
+func TestZerologNormalAndPatternTime(t *testing.T) {
+	for _, formatter := range []string{"normal", "pattern"} {
+		config, out := testMemoryConfig(t, formatter)
+		config.Pattern = "%d %p %m%n"
+		l := testFactory(t, "zerolog").NewPackageLogger("zerolog/"+formatter, config)
+		l.Info("hello")
+		lines := out.lines()
+		if len(lines) != 1 || !normalTime.MatchString(lines[0]) {
+			t.Errorf("%s lines %q", formatter, lines)
+		}
+		if formatter == "pattern" && normalTime.FindString(lines[0]) == "0001-01-01 00:00:00.000" {
+			t.Errorf("pattern time not parsed: %q", lines[0])
+		}
+	}
+}
+
+func TestZerologTimestampDoesNotAllocate(t *testing.T) {
+	logger := zerolog.New(io.Discard).Hook(zerologTimestampHook{})
+	if allocs := testing.AllocsPerRun(100, func() { logger.Info().Msg("hello") }); allocs != 0 {
+		t.Errorf("%v allocations per entry", allocs)
+	}
+}
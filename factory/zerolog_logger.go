@@ -0,0 +1,144 @@
+package factory
+
+import (
+	"github.com/rs/zerolog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+type ZerologLogger struct {
+	name    string
+	level   *atomic.Int32 // zerolog.Level, shared with the children derived by With
+	sink    zerolog.Logger
+	fields  []KeyVal      // bound by With, kept for the entries
+	entries *LoggerConfig // rebuilds the writers of each call, nil unless an appender needs the entries
+	factory *ZerologLoggerFactory
+}
+
+func (l *ZerologLogger) Trace(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlTrace, 0, msg, kvs...)
+}
+func (l *ZerologLogger) Debug(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlDebug, 0, msg, kvs...)
+}
+func (l *ZerologLogger) Info(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlInfo, 0, msg, kvs...)
+}
+func (l *ZerologLogger) Warn(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlWarn, 0, msg, kvs...)
+}
+func (l *ZerologLogger) Error(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlError, 0, msg, kvs...)
+}
+func (l *ZerologLogger) Fatal(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlFatal, 0, msg, kvs...)
+}
+func (l *ZerologLogger) DPanic(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlDPanic, 0, msg, kvs...)
+}
+func (l *ZerologLogger) Panic(msg string, kvs ...KeyVal) {
+	l.LogCaller(LvlPanic, 0, msg, kvs...)
+}
+
+// zerologFunctionFieldName is the field of the caller function, next to zerolog.CallerFieldName.
+const zerologFunctionFieldName = "function"
+
+// LogCaller adds the caller and function fields when pc is set.
+func (l *ZerologLogger) LogCaller(level LevelNum, pc uintptr, msg string, kvs ...KeyVal) {
+	l.log(level, zerologLevel(level), pc, msg, kvs)
+	switch level {
+	case LvlPanic:
+		panic(msg)
+	case LvlFatal:
+		os.Exit(1)
+	}
+}
+
+// log uses WithLevel, zerolog itself would exit or panic on the fatal and panic levels.
+func (l *ZerologLogger) log(levelNum LevelNum, level zerolog.Level, pc uintptr, msg string, kvs []KeyVal) {
+	if level < zerolog.Level(l.level.Load()) {
+		return
+	}
+	caller := callerFrame(pc)
+	sink := l.sink
+	if l.entries != nil {
+		entry := &Entry{
+			Logger:  l.name,
+			Level:   levelNum,
+			Time:    time.Now(),
+			Message: msg,
+			Fields:  joinKeyVals(l.fields, kvs),
+			Caller:  caller,
+		}
+		sink = sink.Output(l.factory.newWriters(l.entries, &entryBridge{entry: entry}))
+	}
+	e := sink.WithLevel(level)
+	if caller != nil {
+		e = e.Str(zerolog.CallerFieldName, zerolog.CallerMarshalFunc(caller.PC, caller.File, caller.Line)).
+			Str(zerologFunctionFieldName, caller.Function)
+	}
+	for _, kv := range kvs {
+		e = l.field(e, kv)
+	}
+	e.Msg(msg)
+}
+
+func (l *ZerologLogger) field(e *zerolog.Event, kv KeyVal) *zerolog.Event {
+	switch v := kv.Val.(type) {
+	case string:
+		return e.Str(kv.Key, v)
+	case int:
+		return e.Int(kv.Key, v)
+	case int64:
+		return e.Int64(kv.Key, v)
+	case uint64:
+		return e.Uint64(kv.Key, v)
+	case float64:
+		return e.Float64(kv.Key, v)
+	case bool:
+		return e.Bool(kv.Key, v)
+	case time.Time:
+		return e.Time(kv.Key, v)
+	case time.Duration:
+		return e.Dur(kv.Key, v)
+	case error:
+		return e.AnErr(kv.Key, v)
+	}
+	return e.Interface(kv.Key, kv.Val)
+}
+
+func (l *ZerologLogger) With(kvs []KeyVal) LoggerDelegate {
+	fields := make([]interface{}, 0, 2*len(kvs))
+	for _, kv := range kvs {
+		fields = append(fields, kv.Key, kv.Val)
+	}
+	return &ZerologLogger{
+		name:    l.name,
+		level:   l.level,
+		sink:    l.sink.With().Fields(fields).Logger(),
+		fields:  joinKeyVals(l.fields, kvs),
+		entries: l.entries,
+		factory: l.factory,
+	}
+}
+
+func zerologLevel(level LevelNum) zerolog.Level {
+	switch level {
+	case LvlTrace:
+		return zerolog.TraceLevel
+	case LvlDebug:
+		return zerolog.DebugLevel
+	case LvlInfo:
+		return zerolog.InfoLevel
+	case LvlWarn:
+		return zerolog.WarnLevel
+	case LvlError:
+		return zerolog.ErrorLevel
+	case LvlDPanic, LvlPanic:
+		return zerolog.PanicLevel
+	case LvlFatal:
+		return zerolog.FatalLevel
+	}
+	return zerolog.InfoLevel
+}
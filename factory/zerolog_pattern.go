@@ -0,0 +1,103 @@
+package factory
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zerologPatternWriter renders the json entries of a logger with a patternLayout,
+// like zerolog.ConsoleWriter does for the normal formatter.
+type zerologPatternWriter struct {
+	name   string
+	layout *patternLayout
+	out    io.Writer
+}
+
+func newZerologPatternWriter(name string, pattern string, out io.Writer) io.Writer {
+	return &zerologPatternWriter{
+		name:   name,
+		layout: mustPatternLayout(pattern),
+		out:    out,
+	}
+}
+
+func (w *zerologPatternWriter) Write(p []byte) (int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	fields := make(map[string]interface{})
+	if err := decoder.Decode(&fields); err != nil {
+		return 0, err
+	}
+	entry := &Entry{Logger: w.name, Level: LvlInfo}
+	if level, ok := fields[zerolog.LevelFieldName].(string); ok {
+		if zerologLevel, err := zerolog.ParseLevel(level); err == nil {
+			entry.Level = zerologLevelNum(zerologLevel)
+		}
+	}
+	if ts, ok := fields[zerolog.TimestampFieldName].(string); ok {
+		entry.Time, _ = time.ParseInLocation(DTFormatNormal, ts, time.Local)
+	}
+	entry.Message, _ = fields[zerolog.MessageFieldName].(string)
+	entry.Caller = zerologCaller(fields)
+	delete(fields, zerolog.LevelFieldName)
+	delete(fields, zerolog.TimestampFieldName)
+	delete(fields, zerolog.MessageFieldName)
+	delete(fields, zerolog.CallerFieldName)
+	delete(fields, zerologFunctionFieldName)
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		entry.Fields = append(entry.Fields, KeyVal{Key: k, Val: fields[k]})
+	}
+	if _, err := w.out.Write(w.layout.format(entry)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// zerologCaller rebuilds the caller from the fields added by ZerologLogger.LogCaller, nil when absent.
+func zerologCaller(fields map[string]interface{}) *runtime.Frame {
+	caller, ok := fields[zerolog.CallerFieldName].(string)
+	if !ok {
+		return nil
+	}
+	frame := &runtime.Frame{File: caller}
+	if idx := strings.LastIndexByte(caller, ':'); idx >= 0 {
+		if line, err := strconv.Atoi(caller[idx+1:]); err == nil {
+			frame.File = caller[:idx]
+			frame.Line = line
+		}
+	}
+	frame.Function, _ = fields[zerologFunctionFieldName].(string)
+	return frame
+}
+
+func zerologLevelNum(level zerolog.Level) LevelNum {
+	switch level {
+	case zerolog.TraceLevel:
+		return LvlTrace
+	case zerolog.DebugLevel:
+		return LvlDebug
+	case zerolog.InfoLevel:
+		return LvlInfo
+	case zerolog.WarnLevel:
+		return LvlWarn
+	case zerolog.ErrorLevel:
+		return LvlError
+	case zerolog.PanicLevel:
+		return LvlPanic
+	case zerolog.FatalLevel:
+		return LvlFatal
+	}
+	return LvlInfo
+}
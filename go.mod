@@ -1,16 +1,22 @@
 module github.com/jeevan86/lf4go
 
-go 1.17
+go 1.21
 
 require go.uber.org/zap v1.21.0
 require github.com/sirupsen/logrus v1.8.1
 require github.com/natefinch/lumberjack/v3 v3.0.0-alpha
+require github.com/rs/zerolog v1.33.0
+require github.com/segmentio/kafka-go v0.4.47
 
 
 require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
-	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
 
 // 现在本地测试